@@ -0,0 +1,117 @@
+package yasctx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ActiveRequests tracks the contexts of in-flight requests, and serves them
+// over HTTP with their logging context (such as on /debug/requests), to help
+// debug stuck requests live.
+// The attributes of a request are read when it is served, from its tracked
+// context. Attributes added with AddWithPropagation anywhere in the request
+// are included, but those added with Add only if they were added before the
+// context was tracked.
+// Memory is bounded by the number of requests tracked at once.
+type ActiveRequests struct {
+	mu     sync.Mutex
+	size   int
+	nextID uint64
+	active map[uint64]activeRequest
+}
+
+// activeRequest is a tracked context, and when it started.
+type activeRequest struct {
+	ctx   context.Context
+	start time.Time
+}
+
+// activeRequestJSON is how an active request is served.
+type activeRequestJSON struct {
+	ID      uint64         `json:"id"`
+	Started time.Time      `json:"started"`
+	Age     string         `json:"age"`
+	Attrs   map[string]any `json:"attrs"`
+}
+
+// NewActiveRequests creates an ActiveRequests tracking at most size requests
+// at once. Requests beyond that are not tracked.
+func NewActiveRequests(size int) *ActiveRequests {
+	return &ActiveRequests{
+		size:   max(size, 1),
+		active: map[uint64]activeRequest{},
+	}
+}
+
+// Track starts tracking the context, until done is called or the context is
+// done, whichever comes first. The start time is that of the clock set by
+// WithClock. If size requests are already tracked, the context is not tracked.
+func (a *ActiveRequests) Track(ctx context.Context) (done func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.active) >= a.size {
+		return func() {}
+	}
+
+	a.nextID++
+	id := a.nextID
+	a.active[id] = activeRequest{ctx: ctx, start: clockFromCtx(ctx).Now()}
+
+	remove := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.active, id)
+	}
+	stop := context.AfterFunc(ctx, remove)
+	return func() {
+		stop()
+		remove()
+	}
+}
+
+// Middleware is an HTTP middleware that tracks the context of each request
+// while it is being served.
+func (a *ActiveRequests) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := a.Track(r.Context())
+		defer done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP serves the tracked requests as JSON, from oldest to newest, with
+// their age and the attributes of their context.
+func (a *ActiveRequests) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	a.mu.Lock()
+	requests := make([]activeRequestJSON, 0, len(a.active))
+	ctxs := make(map[uint64]context.Context, len(a.active))
+	for id, req := range a.active {
+		requests = append(requests, activeRequestJSON{ID: id, Started: req.start})
+		ctxs[id] = req.ctx
+	}
+	a.mu.Unlock()
+
+	// Read the attributes outside of the lock, as the propagation collectors have their own
+	for i := range requests {
+		ctx := ctxs[requests[i].ID]
+		attrs := extractPropagatedAttrs(ctx, time.Time{}, 0, "")
+		requests[i].Attrs = attrsToMap(append(attrs, extractAdded(ctx, time.Time{}, 0, "")...))
+		requests[i].Age = clockFromCtx(ctx).Now().Sub(requests[i].Started).String()
+	}
+	slices.SortFunc(requests, func(x, y activeRequestJSON) int {
+		if x.ID < y.ID {
+			return -1
+		}
+		if x.ID > y.ID {
+			return 1
+		}
+		return 0
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(requests)
+}
@@ -0,0 +1,87 @@
+package yasctx_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestActiveRequests(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: test.DefaultTime}
+	active := yasctx.NewActiveRequests(2)
+
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		active.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/requests", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON; Got: %q", ct)
+		}
+		return rec.Body.String()
+	}
+
+	ctx1 := yasctx.InitPropagation(yasctx.WithClock(context.Background(), clock))
+	ctx1 = yasctx.Add(ctx1, "request_id", "abc")
+	done1 := active.Track(ctx1)
+	// Propagated attributes added after tracking are included
+	yasctx.AddWithPropagation(ctx1, "user_id", 42)
+
+	clock.Advance(time.Second)
+	ctx2, cancel2 := context.WithCancel(yasctx.Add(yasctx.WithClock(context.Background(), clock), "request_id", "def"))
+	active.Track(ctx2)
+
+	// Beyond the size, requests are not tracked
+	doneFull := active.Track(yasctx.Add(context.Background(), "request_id", "ghi"))
+
+	clock.Advance(2 * time.Second)
+	expected := `[{"id":1,"started":"2023-09-29T13:00:59Z","age":"3s","attrs":{"request_id":"abc","user_id":42}},{"id":2,"started":"2023-09-29T13:01:00Z","age":"2s","attrs":{"request_id":"def"}}]
+`
+	if s := serve(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Completed requests are removed, by done or by the context being done
+	doneFull()
+	done1()
+	cancel2()
+	deadline := time.Now().Add(time.Second)
+	for serve() != "[]\n" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s := serve(); s != "[]\n" {
+		t.Errorf("Expected no active requests; Got: %s", s)
+	}
+}
+
+func TestActiveRequestsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	active := yasctx.NewActiveRequests(10)
+	var during string
+	handler := active.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		active.ServeHTTP(rec, r)
+		during = rec.Body.String()
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(yasctx.Add(r.Context(), "path", "/"))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.HasPrefix(during, `[{"id":1,`) || !strings.Contains(during, `"attrs":{"path":"/"}`) {
+		t.Errorf("Expected the request to be active while served; Got: %s", during)
+	}
+
+	rec := httptest.NewRecorder()
+	active.ServeHTTP(rec, r)
+	if s := rec.Body.String(); s != "[]\n" {
+		t.Errorf("Expected no active requests after serving; Got: %s", s)
+	}
+}
@@ -0,0 +1,48 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type allowedKeysKey struct{}
+
+// WithAllowedKeys returns a context in which Add, AddToGroup, AddWithPropagation,
+// AddComputed and the other Add functions silently drop any attribute whose key is not one
+// of keys. This enforces a schema where the attributes are added, so
+// disallowed attributes are never stored, unlike filtering them when logging.
+// Only the top level key is checked, so a group is kept or dropped as a whole.
+// When used again in a subtree, only the keys allowed by both are allowed.
+func WithAllowedKeys(parent context.Context, keys ...string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	parentAllowed, restricted := parent.Value(allowedKeysKey{}).(map[string]bool)
+	allowed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if !restricted || parentAllowed[k] {
+			allowed[k] = true
+		}
+	}
+	return context.WithValue(parent, allowedKeysKey{}, allowed)
+}
+
+// allowedAttrs returns the attributes whose keys are allowed by WithAllowedKeys.
+// The attributes are filtered in place.
+func allowedAttrs(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	allowed, ok := ctx.Value(allowedKeysKey{}).(map[string]bool)
+	if !ok {
+		return attrs
+	}
+	return slices.DeleteFunc(attrs, func(a slog.Attr) bool {
+		return !allowed[a.Key]
+	})
+}
+
+// keyAllowed reports whether the key is allowed by WithAllowedKeys.
+func keyAllowed(ctx context.Context, key string) bool {
+	allowed, ok := ctx.Value(allowedKeysKey{}).(map[string]bool)
+	return !ok || allowed[key]
+}
@@ -0,0 +1,50 @@
+package yasctx
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestWithAllowedKeys(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester))
+
+	ctx := Add(nil, "before", "kept")
+	ctx = WithAllowedKeys(ctx, "request_id", "user_id", "attempt")
+	ctx = Add(ctx, "request_id", "abc", "password", "hunter2")
+	ctx = AddToGroup(ctx, "retry", "attempt", 1, "secret", "x")
+	ctx = AddWithPropagation(ctx, "user_id", 42, "token", "t")
+
+	// Disallowed keys are never stored
+	for _, a := range extractAdded(ctx, time.Time{}, 0, "") {
+		if a.Key == "password" || a.Key == "token" {
+			t.Errorf("Expected %q to be dropped at Add time", a.Key)
+		}
+	}
+	if attrs := extractAddedToGroup(ctx, time.Time{}, 0, "")["retry"]; len(attrs) != 1 {
+		t.Errorf("Expected only the allowed group attribute to be stored; Got %v", attrs)
+	}
+
+	// A nested allowlist can only narrow the allowed keys
+	narrowed := WithAllowedKeys(ctx, "attempt", "password")
+	narrowed = Add(narrowed, "attempt", 2, "password", "hunter2", "request_id", "def")
+
+	// Computed fields are checked by key too
+	ctx = AddComputed(ctx, "secret", func() any { return "leak" })
+	ctx = AddComputed(ctx, "attempt", func() any { return 3 })
+
+	l.WithGroup("retry").InfoContext(ctx, "main message")
+	l.InfoContext(narrowed, "narrowed")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" before=kept request_id=abc user_id=42 attempt=3 retry.attempt=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=narrowed before=kept request_id=abc user_id=42 attempt=2 attempt=1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -7,10 +7,33 @@ import (
 	"time"
 
 	"github.com/pazams/yasctx/internal/attr"
+	"github.com/pazams/yasctx/internal/intern"
 )
 
 type addKey struct{}
 type addToGroupKey struct{}
+type addTextOnlyKey struct{}
+type compactionKey struct{}
+type groupAddModeKey struct{}
+type internKeysKey struct{}
+
+// maxInternedKeys bounds the size of the table used by WithInternedKeys.
+const maxInternedKeys = 4096
+
+// internedKeys is the table shared by all contexts using WithInternedKeys.
+var internedKeys = intern.NewTable(maxInternedKeys)
+
+// GroupAddMode is how AddToGroup treats attributes already added to the same group.
+type GroupAddMode int
+
+const (
+	// GroupAddMerge appends the attributes to those already added to the
+	// group, like slog.Logger.With.
+	GroupAddMerge GroupAddMode = iota
+
+	// GroupAddReplace replaces the attributes already added to the group.
+	GroupAddReplace
+)
 
 // Add adds the attribute arguments at the root level
 func Add(parent context.Context, args ...any) context.Context {
@@ -18,26 +41,149 @@ func Add(parent context.Context, args ...any) context.Context {
 		parent = context.Background()
 	}
 
+	attrs := internKeys(parent, allowedAttrs(parent, attr.ArgsToAttrSlice(args)))
 	if v, ok := parent.Value(addKey{}).([]slog.Attr); ok {
 		// Clip to ensure this is a scoped copy
-		return context.WithValue(parent, addKey{}, append(slices.Clip(v), attr.ArgsToAttrSlice(args)...))
+		return context.WithValue(parent, addKey{}, compact(parent, append(slices.Clip(v), attrs...)))
 	}
-	return context.WithValue(parent, addKey{}, attr.ArgsToAttrSlice(args))
+	return context.WithValue(parent, addKey{}, compact(parent, attrs))
 }
 
 // AddToGroup adds the attribute arguments at a group level
 // If the future log line does not use the group, it will default to the root level.
 func AddToGroup(parent context.Context, group string, args ...any) context.Context {
+	return AddToGroups(parent, []string{group}, args...)
+}
+
+// AddToGroups adds the attribute arguments to each of the groups (such as both
+// "request" and "audit"), so they appear in every group used by a future log line.
+// Any group not used by the log line defaults to the root level, like AddToGroup.
+func AddToGroups(parent context.Context, groups []string, args ...any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	// Copy the map, so that the parent context (and any of its other children) are not modified
+	v, _ := parent.Value(addToGroupKey{}).(map[string][]slog.Attr)
+	m := make(map[string][]slog.Attr, len(v)+len(groups))
+	for k, attrs := range v {
+		m[k] = attrs
+	}
+	attrs := internKeys(parent, allowedAttrs(parent, attr.ArgsToAttrSlice(args)))
+	mode, _ := parent.Value(groupAddModeKey{}).(GroupAddMode)
+	for _, group := range groups {
+		if mode == GroupAddReplace {
+			m[group] = slices.Clip(attrs)
+			continue
+		}
+		// Clip to ensure each group gets its own scoped copy
+		m[group] = compact(parent, append(slices.Clip(m[group]), attrs...))
+	}
+	return context.WithValue(parent, addToGroupKey{}, m)
+}
+
+// WithGroupAddMode sets how AddToGroup and AddToGroups treat attributes
+// already added to the same group, in the returned context (and contexts
+// derived from it). Default is GroupAddMerge.
+// Parent contexts are never modified, whatever the mode.
+func WithGroupAddMode(parent context.Context, mode GroupAddMode) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, groupAddModeKey{}, mode)
+}
+
+// WithCompaction enables compaction of the attributes stored by Add and
+// AddToGroup in the returned context (and contexts derived from it).
+// Once more than threshold attributes are stored at the root level or in a
+// group, those with duplicate keys are collapsed, with the last value winning,
+// like HandlerOptions.Dedup. This bounds the memory and the work per log line
+// of long-lived contexts that repeatedly add the same keys.
+// Compaction changes the stored attributes, so duplicates are no longer
+// available to handlers that would keep them.
+// Values are compared by key only, so a LogValuer is still resolved when
+// logged rather than when compacted.
+func WithCompaction(parent context.Context, threshold int) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, compactionKey{}, threshold)
+}
+
+// WithInternedKeys enables interning of the keys of the attributes stored by
+// Add and AddToGroup in the returned context (and contexts derived from it).
+// Keys built at runtime (such as those decoded from a request) then share
+// their memory across the contexts that hold them, reducing the memory of
+// many live contexts. It costs a table lookup per key when added, and nothing
+// per log line, so it only pays off for contexts that are kept alive (see
+// BenchmarkWithInternedKeys). The table is shared, and bounded in size.
+func WithInternedKeys(parent context.Context) context.Context {
 	if parent == nil {
 		parent = context.Background()
 	}
+	return context.WithValue(parent, internKeysKey{}, true)
+}
+
+// internKeys interns the keys of the attributes in place, if WithInternedKeys is used.
+func internKeys(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	if ctx.Value(internKeysKey{}) == nil {
+		return attrs
+	}
+	for i := range attrs {
+		attrs[i].Key = internedKeys.Intern(attrs[i].Key)
+	}
+	return attrs
+}
+
+// compact returns the attributes with duplicate keys collapsed, if
+// WithCompaction is used and there are more attributes than its threshold.
+// LogValuers are kept unresolved until the attributes are logged.
+func compact(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	if threshold, ok := ctx.Value(compactionKey{}).(int); ok && len(attrs) > threshold {
+		return compactAttrs(attrs)
+	}
+	return attrs
+}
 
-	if v, ok := parent.Value(addToGroupKey{}).(map[string][]slog.Attr); ok {
-		v[group] = append(slices.Clip(v[group]), attr.ArgsToAttrSlice(args)...)
-		return context.WithValue(parent, addToGroupKey{}, v)
+// AddTextOnly adds the attribute arguments at the root level, but only for
+// handlers with HandlerOptions.TextOutput enabled. This keeps verbose
+// attributes meant for humans (such as a pretty-printed request) out of the
+// machine readable JSON output.
+func AddTextOnly(parent context.Context, args ...any) context.Context {
+	if parent == nil {
+		parent = context.Background()
 	}
-	return context.WithValue(parent, addToGroupKey{}, map[string][]slog.Attr{
-		group: attr.ArgsToAttrSlice(args),
+
+	v, _ := parent.Value(addTextOnlyKey{}).([]slog.Attr)
+	// Clip to ensure this is a scoped copy
+	return context.WithValue(parent, addTextOnlyKey{}, append(slices.Clip(v), allowedAttrs(parent, attr.ArgsToAttrSlice(args))...))
+}
+
+// Capture adds the attributes of the record at the root level, so that future
+// log lines using the returned context inherit them (such as after an initial
+// "request started" log line that sets the baseline fields).
+// Groups in the record are preserved.
+func Capture(parent context.Context, r slog.Record) context.Context {
+	args := make([]any, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		args = append(args, a)
+		return true
+	})
+	return Add(parent, args...)
+}
+
+// OnContextDone registers fn to be called, in its own goroutine, with the
+// context's final root level attributes (those added with Add and
+// AddWithPropagation) once the context is canceled or its deadline passes.
+// This is useful to emit a summary log line at the end of a request.
+// No goroutine is kept waiting on the context in the meantime, and a context
+// that is never done never calls fn.
+// Calling the returned stop function unregisters fn, and reports whether it
+// did so before fn was called.
+func OnContextDone(ctx context.Context, fn func(attrs []slog.Attr)) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		attrs := extractPropagatedAttrs(ctx, time.Time{}, 0, "")
+		fn(append(attrs, extractAdded(ctx, time.Time{}, 0, "")...))
 	})
 }
 
@@ -56,3 +202,12 @@ func extractAddedToGroup(ctx context.Context, _ time.Time, _ slog.Level, _ strin
 	}
 	return nil
 }
+
+// extractTextOnly returns the attributes added with AddTextOnly.
+// The returned slice should not be appended to or modified in any way. Doing so will cause a race condition.
+func extractTextOnly(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if v, ok := ctx.Value(addTextOnlyKey{}).([]slog.Attr); ok {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package yasctx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestNoLeakAcrossRequests(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester)).With("service", "api").WithGroup("req")
+
+	// Both requests derive from the same base context, as they would from a server's base context
+	base := Add(nil, "env", "test")
+	base = AddToGroup(base, "req", "base", true)
+
+	const iterations = 100
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ctx := Add(base, "request_id", id)
+			ctx = AddToGroup(ctx, "req", "user", "user-"+id)
+			ctx = AddToGroup(ctx, "other", "orphan", "orphan-"+id)
+			rl := l.With("logger", id)
+			for i := 0; i < iterations; i++ {
+				rl.InfoContext(ctx, "request "+id, "i", i)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(tester.Records) != 2*iterations {
+		t.Fatalf("Expected %d records; Got: %d", 2*iterations, len(tester.Records))
+	}
+
+	for _, r := range tester.Records {
+		id := strings.TrimPrefix(r.Message, "request ")
+		other := map[string]string{"a": "b", "b": "a"}[id]
+
+		line := (&test.Handler{Records: []slog.Record{r}}).String()
+		for _, expected := range []string{
+			"request_id=" + id, "orphan=orphan-" + id, "req.user=user-" + id, "req.logger=" + id, "env=test", "req.base=true", "service=api",
+		} {
+			if !strings.Contains(line, " "+expected+" ") {
+				t.Errorf("Expected %q in line: %s", expected, line)
+			}
+		}
+		for _, unexpected := range []string{"=" + other + " ", "-" + other + " "} {
+			if strings.Contains(line, unexpected) {
+				t.Errorf("Found attribute of request %q in line: %s", other, line)
+			}
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester))
+
+	r := slog.NewRecord(test.DefaultTime, slog.LevelInfo, "request started", 0)
+	r.AddAttrs(slog.String("method", "GET"), slog.Group("user", "id", 24680))
+
+	ctx := Add(nil, "existing", "arg1")
+	captured := Capture(ctx, r)
+	l.InfoContext(captured, "next message", "main1", "arg1")
+	l.InfoContext(ctx, "parent message")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="next message" existing=arg1 method=GET user.id=24680 main1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="parent message" existing=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestOnContextDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(InitPropagation(nil))
+	ctx = Add(ctx, "request_id", "abc")
+
+	done := make(chan []slog.Attr, 1)
+	OnContextDone(ctx, func(attrs []slog.Attr) { done <- attrs })
+
+	// Attributes propagated after registering are still included
+	AddWithPropagation(ctx, "user_id", 24680)
+
+	stopped := make(chan []slog.Attr, 1)
+	stop := OnContextDone(ctx, func(attrs []slog.Attr) { stopped <- attrs })
+	if !stop() {
+		t.Error("Expected stop to unregister the callback")
+	}
+
+	cancel()
+
+	select {
+	case attrs := <-done:
+		s := fmt.Sprint(attrs)
+		if s != "[user_id=24680 request_id=abc]" {
+			t.Errorf("Unexpected attributes: %s", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected callback to be called")
+	}
+
+	select {
+	case <-stopped:
+		t.Error("Expected stopped callback to not be called")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestAddToGroups(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester))
+
+	ctx := AddToGroup(nil, "audit", "actor", "alice")
+	ctx = AddToGroups(ctx, []string{"request", "audit"}, "request_id", "abc")
+	AddToGroups(ctx, []string{"request", "audit"}, "leaked", true) // Ensure we aren't overwriting the parent context
+
+	l.WithGroup("request").With("method", "GET").WithGroup("audit").InfoContext(ctx, "main message", "action", "delete")
+	l.WithGroup("request").InfoContext(ctx, "unused group")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request.request_id=abc request.method=GET request.audit.actor=alice request.audit.request_id=abc request.audit.action=delete
+time=2023-09-29T13:00:59.000Z level=INFO msg="unused group" actor=alice request_id=abc request.request_id=abc
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestAddTextOnly(t *testing.T) {
+	t.Parallel()
+
+	ctx := Add(nil, "request_id", "abc")
+	ctx = AddTextOnly(ctx, "headers", "Accept: */*")
+
+	for _, tc := range []struct {
+		textOutput bool
+		expected   string
+	}{
+		{
+			textOutput: true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=abc headers="Accept: */*" main1=arg1
+`,
+		},
+		{
+			textOutput: false,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=abc main1=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{TextOutput: tc.textOutput}))
+		l.InfoContext(ctx, "main message", "main1", "arg1")
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("TextOutput %v expected:\n%s\nGot:\n%s\n", tc.textOutput, tc.expected, s)
+		}
+	}
+}
+
+func TestWithCompaction(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester))
+
+	ctx := WithCompaction(nil, 4)
+	for i := 0; i < 10; i++ {
+		ctx = Add(ctx, "attempt", i, "status", fmt.Sprint("status", i))
+		ctx = AddToGroup(ctx, "retry", "attempt", i)
+	}
+	ctx = Add(ctx, "request_id", "abc")
+
+	if n := len(extractAdded(ctx, time.Time{}, 0, "")); n > 4 {
+		t.Errorf("Expected compacted root attributes; Got %d", n)
+	}
+	if n := len(extractAddedToGroup(ctx, time.Time{}, 0, "")["retry"]); n > 4 {
+		t.Errorf("Expected compacted group attributes; Got %d", n)
+	}
+
+	// Duplicates are kept until the threshold is exceeded again
+	l.WithGroup("retry").InfoContext(ctx, "main message")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" attempt=9 status=status9 request_id=abc retry.attempt=8 retry.attempt=9
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Without compaction, all duplicates are stored
+	uncompacted := context.Background()
+	for i := 0; i < 10; i++ {
+		uncompacted = Add(uncompacted, "attempt", i)
+	}
+	if n := len(extractAdded(uncompacted, time.Time{}, 0, "")); n != 10 {
+		t.Errorf("Expected 10 uncompacted attributes; Got %d", n)
+	}
+}
+
+type countingValuer struct {
+	calls *int
+}
+
+func (v countingValuer) LogValue() slog.Value {
+	*v.calls++
+	return slog.IntValue(*v.calls)
+}
+
+func TestWithCompactionLazy(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester))
+
+	var calls int
+	ctx := WithCompaction(nil, 1)
+	ctx = Add(ctx, "user", countingValuer{calls: &calls}, "request_id", "abc", "request_id", "def")
+	ctx = Add(ctx, "user", countingValuer{calls: &calls})
+
+	if calls != 0 {
+		t.Errorf("Expected no resolved values after compaction; Got %d", calls)
+	}
+
+	l.InfoContext(ctx, "main message")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user=1 request_id=def
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestWithGroupAddMode(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandler(tester)).WithGroup("req")
+
+	base := AddToGroup(nil, "req", "path", "/users", "attempt", 1)
+
+	merged := AddToGroup(base, "req", "attempt", 2)
+	replaced := WithGroupAddMode(base, GroupAddReplace)
+	replaced = AddToGroups(replaced, []string{"req", "other"}, "attempt", 2)
+	// Merging again after a replace appends to the replacement
+	remerged := AddToGroup(WithGroupAddMode(replaced, GroupAddMerge), "req", "done", true)
+
+	l.InfoContext(merged, "merged")
+	l.InfoContext(replaced, "replaced")
+	l.InfoContext(remerged, "remerged")
+	l.InfoContext(base, "base")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=merged req.path=/users req.attempt=1 req.attempt=2
+time=2023-09-29T13:00:59.000Z level=INFO msg=replaced attempt=2 req.attempt=2
+time=2023-09-29T13:00:59.000Z level=INFO msg=remerged attempt=2 req.attempt=2 req.done=true
+time=2023-09-29T13:00:59.000Z level=INFO msg=base req.path=/users req.attempt=1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,91 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pazams/yasctx/internal/attr"
+)
+
+type auditKey struct{}
+
+// AuditWriter writes audit attributes to an audit store (such as a database
+// table). Its methods must be safe for concurrent use.
+type AuditWriter interface {
+	// WriteAudit is called synchronously, once for each log line using a
+	// context with attributes added by AddAudit, with the time and message of
+	// the log line and the audit attributes.
+	WriteAudit(ctx context.Context, t time.Time, msg string, attrs []slog.Attr) error
+}
+
+// auditEntry holds the attributes added by AddAudit, and the last record
+// written to the audit store with them, so that each record is written once
+// even when it is passed to several Handlers (such as behind a fanout).
+type auditEntry struct {
+	attrs []slog.Attr
+
+	mu     sync.Mutex
+	time   time.Time
+	pc     uintptr
+	logged bool
+}
+
+// claim reports whether the record has not been audited yet, recording it as
+// audited.
+func (e *auditEntry) claim(r slog.Record) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.logged && r.Time.Equal(e.time) && r.PC == e.pc {
+		return false
+	}
+	e.logged = true
+	e.time, e.pc = r.Time, r.PC
+	return true
+}
+
+// AddAudit adds the attribute arguments as audit attributes (such as the
+// acting user and the action taken). Set HandlerOptions.Audit to an
+// AuditExtractor to include them in log lines and write them to an AuditWriter.
+func AddAudit(parent context.Context, args ...any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	var v []slog.Attr
+	if entry, ok := parent.Value(auditKey{}).(*auditEntry); ok {
+		v = entry.attrs
+	}
+	// Clip to ensure this is a scoped copy
+	return context.WithValue(parent, auditKey{}, &auditEntry{attrs: append(slices.Clip(v), allowedAttrs(parent, attr.ArgsToAttrSlice(args))...)})
+}
+
+// auditAttrs returns the attributes added by AddAudit.
+func auditAttrs(ctx context.Context) []slog.Attr {
+	if entry, ok := ctx.Value(auditKey{}).(*auditEntry); ok {
+		return entry.attrs
+	}
+	return nil
+}
+
+// AuditExtractor returns an AttrExtractor that writes the attributes added by
+// AddAudit to w, and adds them to the log line. Set it as HandlerOptions.Audit,
+// which calls it before sampling and whatever the level of the log line, once
+// per log line.
+// If the write fails, onError is called with the error (such as to alert, or
+// retry), and the log line is still handled. onError may be nil, in which
+// case errors are ignored.
+func AuditExtractor(w AuditWriter, onError func(ctx context.Context, err error)) AttrExtractor {
+	return func(ctx context.Context, recordT time.Time, _ slog.Level, recordMsg string) []slog.Attr {
+		attrs := auditAttrs(ctx)
+		if len(attrs) == 0 {
+			return nil
+		}
+		if err := w.WriteAudit(ctx, recordT, recordMsg, attrs); err != nil && onError != nil {
+			onError(ctx, err)
+		}
+		return attrs
+	}
+}
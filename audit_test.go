@@ -0,0 +1,142 @@
+package yasctx_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+// fakeAuditWriter is a yasctx.AuditWriter that captures the audit entries.
+type fakeAuditWriter struct {
+	mu      sync.Mutex
+	entries []string
+	err     error
+}
+
+func (w *fakeAuditWriter) WriteAudit(_ context.Context, t time.Time, msg string, attrs []slog.Attr) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.entries = append(w.entries, t.Format(time.RFC3339)+" "+msg+" "+slog.GroupValue(attrs...).String())
+	return nil
+}
+
+func TestAuditExtractor(t *testing.T) {
+	t.Parallel()
+
+	w := &fakeAuditWriter{}
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Audit: yasctx.AuditExtractor(w, nil),
+		Clock: &fakeClock{now: test.DefaultTime},
+	}))
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	l.InfoContext(ctx, "not audited")
+
+	ctx = yasctx.AddAudit(ctx, "actor", "alice", "action", "delete")
+	l.InfoContext(ctx, "deleted", "id", 1)
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="not audited" request_id=abc
+time=2023-09-29T13:00:59.000Z level=INFO msg=deleted request_id=abc id=1 actor=alice action=delete
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	expectedEntries := []string{"2023-09-29T13:00:59Z deleted [actor=alice action=delete]"}
+	if len(w.entries) != 1 || w.entries[0] != expectedEntries[0] {
+		t.Errorf("Expected audit entries %q; Got: %q", expectedEntries, w.entries)
+	}
+}
+
+func TestAuditExtractorError(t *testing.T) {
+	t.Parallel()
+
+	w := &fakeAuditWriter{err: errors.New("store down")}
+	var errs []error
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Audit: yasctx.AuditExtractor(w, func(_ context.Context, err error) {
+			errs = append(errs, err)
+		}),
+	}))
+
+	l.InfoContext(yasctx.AddAudit(context.Background(), "actor", "alice"), "login")
+
+	// The log line is still written
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=login actor=alice
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], w.err) {
+		t.Errorf("Expected the write error to be surfaced; Got: %v", errs)
+	}
+}
+
+func TestAuditExtractorNotSampled(t *testing.T) {
+	t.Parallel()
+
+	w := &fakeAuditWriter{}
+	var buf bytes.Buffer
+	l := slog.New(yasctx.NewHandlerWithOptions(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError}), &yasctx.HandlerOptions{
+		Audit:      yasctx.AuditExtractor(w, nil),
+		SampleRate: 1e-9,
+		Clock:      &fakeClock{now: test.DefaultTime},
+	}))
+
+	ctx := yasctx.AddAudit(context.Background(), "actor", "alice")
+	l.InfoContext(ctx, "below the level")
+	l.ErrorContext(yasctx.WithSampleSeed(ctx, "abc"), "sampled out")
+	l.InfoContext(context.Background(), "not audited")
+
+	// Neither line is logged, but both are audited
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log lines; Got:\n%s", buf.String())
+	}
+	expectedEntries := []string{
+		"2023-09-29T13:00:59Z below the level [actor=alice]",
+		"2023-09-29T13:00:59Z sampled out [actor=alice]",
+	}
+	if !slices.Equal(w.entries, expectedEntries) {
+		t.Errorf("Expected audit entries %q; Got: %q", expectedEntries, w.entries)
+	}
+}
+
+func TestAuditExtractorFanout(t *testing.T) {
+	t.Parallel()
+
+	w := &fakeAuditWriter{}
+	opts := &yasctx.HandlerOptions{Audit: yasctx.AuditExtractor(w, nil)}
+	tester1 := &test.Handler{}
+	tester2 := &test.Handler{}
+	l := slog.New(fanout{yasctx.NewHandlerWithOptions(tester1, opts), yasctx.NewHandlerWithOptions(tester2, opts)})
+
+	ctx := yasctx.AddAudit(context.Background(), "actor", "alice")
+	l.InfoContext(ctx, "deleted")
+	l.InfoContext(ctx, "deleted")
+
+	// Every sink logs the audit attributes, but each line is written once
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=deleted actor=alice
+time=2023-09-29T13:00:59.000Z level=INFO msg=deleted actor=alice
+`
+	for i, tester := range []*test.Handler{tester1, tester2} {
+		if s := tester.String(); s != expected {
+			t.Errorf("Sink %d expected:\n%s\nGot:\n%s\n", i, expected, s)
+		}
+	}
+	if len(w.entries) != 2 {
+		t.Errorf("Expected 2 audit entries; Got: %q", w.entries)
+	}
+}
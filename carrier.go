@@ -0,0 +1,59 @@
+package yasctx
+
+import "context"
+
+// Carrier reads the metadata of a message (such as SQS message attributes or
+// Kafka headers) by key, returning "" if the key is missing. It keeps the
+// types of messaging SDKs out of this package.
+type Carrier interface {
+	Get(key string) string
+}
+
+// MapCarrier is a Carrier of a map, such as the one returned by InjectMap.
+type MapCarrier map[string]string
+
+// Get returns the value of the key.
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+// CarrierFunc adapts a function to a Carrier, such as for SQS message attributes:
+//
+//	yasctx.CarrierFunc(func(key string) string {
+//		if v, ok := msg.MessageAttributes[key]; ok && v.StringValue != nil {
+//			return *v.StringValue
+//		}
+//		return ""
+//	})
+//
+// or for Kafka headers:
+//
+//	yasctx.CarrierFunc(func(key string) string {
+//		for _, h := range msg.Headers {
+//			if h.Key == key {
+//				return string(h.Value)
+//			}
+//		}
+//		return ""
+//	})
+type CarrierFunc func(key string) string
+
+// Get returns the value of the key.
+func (f CarrierFunc) Get(key string) string {
+	return f(key)
+}
+
+// ExtractFromCarrier rehydrates the logging context of a consumed message.
+// It adds the attributes propagated by InjectMap (stored under
+// PropagationKey), and the "trace_id" and "span_id" of a W3C "traceparent"
+// entry, to the context with AddWithPropagation.
+// Entries that are missing or malformed are skipped.
+func ExtractFromCarrier(ctx context.Context, c Carrier) context.Context {
+	if s := c.Get(PropagationKey); s != "" {
+		ctx = extractPropagationText(ctx, s)
+	}
+	if traceID, spanID, ok := parseTraceParent(c.Get("traceparent")); ok {
+		ctx = AddWithPropagation(ctx, "trace_id", traceID, "span_id", spanID)
+	}
+	return ctx
+}
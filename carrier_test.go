@@ -0,0 +1,77 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+// sqsAttributeValue mirrors the shape of the SQS SDK's MessageAttributeValue.
+type sqsAttributeValue struct {
+	DataType    *string
+	StringValue *string
+}
+
+// kafkaHeader mirrors the shape of the headers of Kafka clients.
+type kafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+func TestExtractFromCarrier(t *testing.T) {
+	t.Parallel()
+
+	producerCtx := yasctx.AddWithPropagation(yasctx.InitPropagation(context.Background()), "request_id", "abc")
+	propagated := yasctx.InjectMap(producerCtx)[yasctx.PropagationKey]
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	dataType := "String"
+
+	sqsAttributes := map[string]sqsAttributeValue{
+		yasctx.PropagationKey: {DataType: &dataType, StringValue: &propagated},
+		"traceparent":         {DataType: &dataType, StringValue: &traceParent},
+	}
+	kafkaHeaders := []kafkaHeader{
+		{Key: "content-type", Value: []byte("application/json")},
+		{Key: yasctx.PropagationKey, Value: []byte(propagated)},
+		{Key: "traceparent", Value: []byte(traceParent)},
+	}
+
+	carriers := map[string]yasctx.Carrier{
+		"sqs": yasctx.CarrierFunc(func(key string) string {
+			if v, ok := sqsAttributes[key]; ok && v.StringValue != nil {
+				return *v.StringValue
+			}
+			return ""
+		}),
+		"kafka": yasctx.CarrierFunc(func(key string) string {
+			for _, h := range kafkaHeaders {
+				if h.Key == key {
+					return string(h.Value)
+				}
+			}
+			return ""
+		}),
+		"map": yasctx.MapCarrier{yasctx.PropagationKey: propagated, "traceparent": traceParent},
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=consumed request_id=abc trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7
+`
+	for name, carrier := range carriers {
+		tester := &test.Handler{}
+		ctx := yasctx.ExtractFromCarrier(yasctx.InitPropagation(context.Background()), carrier)
+		slog.New(yasctx.NewHandler(tester)).InfoContext(ctx, "consumed")
+
+		if s := tester.String(); s != expected {
+			t.Errorf("%s expected:\n%s\nGot:\n%s\n", name, expected, s)
+		}
+	}
+
+	// Missing and malformed entries are skipped
+	malformed := yasctx.MapCarrier{yasctx.PropagationKey: "!!!", "traceparent": "00-bad"}
+	if ctx := yasctx.ExtractFromCarrier(context.Background(), malformed); ctx != context.Background() {
+		t.Error("Expected unchanged context for malformed entries")
+	}
+}
@@ -0,0 +1,207 @@
+// Package cef provides a slog.Handler that writes log lines in the Common Event
+// Format (CEF), for integration with security information and event management
+// (SIEM) systems.
+//
+// It is intended to be used as the next handler of a yasctx.Handler, so that
+// the attributes found in the context are included in the CEF extension:
+//
+//	slog.SetDefault(slog.New(yasctx.NewHandler(cef.NewHandler(os.Stdout, &cef.Options{
+//		Vendor:  "Acme",
+//		Product: "Billing",
+//		Version: "1.0",
+//	}))))
+package cef
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Options are options for a Handler
+type Options struct {
+	// Vendor is the Device Vendor header field.
+	Vendor string
+
+	// Product is the Device Product header field.
+	Product string
+
+	// Version is the Device Version header field.
+	Version string
+
+	// SignatureID is the Signature ID header field, which identifies the type
+	// of event. If nil, the message of the log line is used.
+	SignatureID func(r slog.Record) string
+
+	// Level is the minimum level of log lines written.
+	// Default is slog.LevelInfo.
+	Level slog.Leveler
+}
+
+// Handler is a slog.Handler that writes each log line as a CEF line.
+// The message of the log line is the Name header field, and its level is
+// mapped to the Severity header field (from 0 to 10).
+// The time of the log line is the "rt" extension field, in milliseconds since
+// the Unix epoch, followed by the attributes. Attributes in groups are
+// flattened, with their keys prefixed by the group names and ".".
+type Handler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	opts   Options
+	prefix string      // Prefix of the keys, from WithGroup
+	attrs  []slog.Attr // Already prefixed, from WithAttrs
+}
+
+var _ slog.Handler = &Handler{} // Assert conformance with interface
+
+// NewHandler creates a Handler that writes to w, configured by opts.
+// If opts is nil, the default options are used.
+func NewHandler(w io.Writer, opts *Options) *Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	return &Handler{
+		mu:   &sync.Mutex{},
+		w:    w,
+		opts: *opts,
+	}
+}
+
+// Enabled reports whether the level is at least the minimum level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle writes the record as a single CEF line.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	signatureID := r.Message
+	if h.opts.SignatureID != nil {
+		signatureID = h.opts.SignatureID(r)
+	}
+
+	buf := []byte("CEF:0|")
+	for _, field := range []string{h.opts.Vendor, h.opts.Product, h.opts.Version, signatureID, r.Message} {
+		buf = appendHeader(buf, field)
+		buf = append(buf, '|')
+	}
+	buf = strconv.AppendInt(buf, int64(severity(r.Level)), 10)
+	buf = append(buf, '|')
+
+	ext := make([]slog.Attr, 0, len(h.attrs)+r.NumAttrs()+1)
+	if !r.Time.IsZero() {
+		ext = append(ext, slog.Int64("rt", r.Time.UnixMilli()))
+	}
+	ext = append(ext, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		ext = flatten(ext, h.prefix, a)
+		return true
+	})
+	for i, a := range ext {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = appendExtension(buf, a.Key)
+		buf = append(buf, '=')
+		buf = appendExtension(buf, a.Value.String())
+	}
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+// WithGroup returns a new Handler whose attributes are prefixed by the group name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// WithAttrs returns a new Handler whose log lines include the attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = slices.Clip(h.attrs)
+	for _, a := range attrs {
+		h2.attrs = flatten(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+// flatten appends the attribute to dst, replacing a group with its attributes
+// whose keys are prefixed by the group name. Empty attributes are dropped.
+func flatten(dst []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Equal(slog.Attr{}) {
+			return dst
+		}
+		a.Key = prefix + a.Key
+		return append(dst, a)
+	}
+	// Groups with an empty key are inlined
+	if a.Key != "" {
+		prefix = prefix + a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		dst = flatten(dst, prefix, ga)
+	}
+	return dst
+}
+
+// severity maps the level to a CEF severity, from 0 (lowest) to 10 (highest),
+// such that Debug is 0, Info is 3, Warn is 6, and Error is 9.
+func severity(level slog.Level) int {
+	return min(max(3+int(level)*3/4, 0), 10)
+}
+
+// appendHeader appends a header field, escaping pipes and backslashes.
+func appendHeader(buf []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '|', '\\':
+			buf = append(buf, '\\', c)
+		case '\n', '\r':
+			buf = append(buf, ' ')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// appendExtension appends an extension key or value, escaping equal signs,
+// backslashes, and newlines.
+func appendExtension(buf []byte, s string) []byte {
+	if !strings.ContainsAny(s, "=\\\n\r") {
+		return append(buf, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '=', '\\':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
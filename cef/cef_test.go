@@ -0,0 +1,63 @@
+package cef_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/cef"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	h := yasctx.NewHandler(cef.NewHandler(buf, &cef.Options{
+		Vendor:      "Acme",
+		Product:     "Billing|Service",
+		Version:     "1.0",
+		SignatureID: func(r slog.Record) string { return "login" },
+	}))
+
+	ctx := yasctx.Add(context.Background(), "suser", "alice", "src", "10.0.0.1")
+	ctx = yasctx.AddToGroup(ctx, "request", "method", "POST")
+
+	l := slog.New(h).WithGroup("request").With("path", "/login")
+	r := slog.NewRecord(test.DefaultTime, slog.LevelWarn, "failed login", 0)
+	r.AddAttrs(slog.String("reason", "bad=password\nagain"))
+	if err := l.Handler().Handle(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `CEF:0|Acme|Billing\|Service|1.0|login|failed login|6|rt=1695992459000 suser=alice src=10.0.0.1 request.method=POST request.path=/login request.reason=bad\=password\nagain
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerWithAttrs(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	h := cef.NewHandler(buf, nil).WithAttrs([]slog.Attr{slog.String("app", "billing")}).WithGroup("g").WithAttrs([]slog.Attr{slog.Int("n", 1)})
+
+	r := slog.NewRecord(test.DefaultTime, slog.LevelError, "boom", 0)
+	r.AddAttrs(slog.Group("sub", "k", "v"))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `CEF:0||||boom|boom|9|rt=1695992459000 app=billing g.n=1 g.sub.k=v
+`
+	if s := buf.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	if h.Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("Expected debug to be disabled by default")
+	}
+}
@@ -0,0 +1,38 @@
+package yasctx
+
+import (
+	"context"
+	"time"
+)
+
+type clockKey struct{}
+
+// Clock tells the current time. It lets tests replace the real time with a
+// fake clock, to deterministically verify time based behavior.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock of the real time.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// WithClock sets the clock used by MarkStart and AddWithTTL for the returned
+// context (and contexts derived from it). Default is the real time.
+// Set the same clock as HandlerOptions.Clock to deterministically verify the
+// attributes of log lines in tests.
+func WithClock(parent context.Context, clock Clock) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, clockKey{}, clock)
+}
+
+// clockFromCtx returns the clock set by WithClock, or the real time.
+func clockFromCtx(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return realClock{}
+}
@@ -0,0 +1,139 @@
+package yasctx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+type computedKey struct{}
+
+// computedTimeout bounds how long a computed field may take before it is abandoned.
+const computedTimeout = 100 * time.Millisecond
+
+// computedField is a named field whose value is computed whenever a log line is written.
+type computedField struct {
+	key   string
+	fn    func() any
+	state *computedState
+}
+
+// computedState tracks the evaluations of a computed field.
+type computedState struct {
+	mu      sync.Mutex
+	slow    bool // fn once took longer than computedTimeout, so it is called in the background
+	running bool // A background call of fn is in flight
+	last    any
+	hasLast bool
+}
+
+// AddComputed adds a field whose value is computed by calling fn each time a
+// log line using the returned context is written, so that log lines show its
+// current value (such as the depth of a queue).
+// fn must be fast and must not block, as it is called while logging.
+// If fn panics, the value is the string "!PANIC: " followed by the panic value.
+// Once a call of fn takes longer than 100ms, later calls are made in the
+// background, and are left to finish if they take longer than 100ms, with the
+// value being the last one computed. At most one background call is in flight
+// at a time, so log lines written while it runs also use the last value,
+// instead of calling fn again.
+func AddComputed(parent context.Context, key string, fn func() any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if !keyAllowed(parent, key) {
+		return parent
+	}
+	v, _ := parent.Value(computedKey{}).([]computedField)
+	// Clip to ensure this is a scoped copy
+	return withFeature(context.WithValue(parent, computedKey{}, append(slices.Clip(v), computedField{key: key, fn: fn, state: &computedState{}})), featureComputed)
+}
+
+// extractComputed returns the current values of the fields added with AddComputed.
+func extractComputed(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	v, ok := ctx.Value(computedKey{}).([]computedField)
+	if !ok {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, len(v))
+	for _, field := range v {
+		attrs = append(attrs, slog.Any(field.key, field.compute()))
+	}
+	return attrs
+}
+
+// compute calls fn, guarding against panics and slow functions.
+func (f computedField) compute() any {
+	s := f.state
+	s.mu.Lock()
+	slow := s.slow
+	s.mu.Unlock()
+	if slow {
+		return f.computeInBackground()
+	}
+
+	start := time.Now()
+	value := f.call()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last, s.hasLast = value, true
+	if time.Since(start) > computedTimeout {
+		s.slow = true
+	}
+	return value
+}
+
+// computeInBackground calls fn in a goroutine, abandoning it if it takes longer
+// than computedTimeout, with at most one call in flight.
+func (f computedField) computeInBackground() any {
+	s := f.state
+	s.mu.Lock()
+	if s.running {
+		defer s.mu.Unlock()
+		return s.lastOr("!IN_FLIGHT")
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	result := make(chan any, 1) // Buffered, so an abandoned fn can still finish
+	go func() {
+		value := f.call()
+		s.mu.Lock()
+		s.running, s.last, s.hasLast = false, value, true
+		s.mu.Unlock()
+		result <- value
+	}()
+
+	timer := time.NewTimer(computedTimeout)
+	defer timer.Stop()
+	select {
+	case value := <-result:
+		return value
+	case <-timer.C:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.lastOr("!TIMEOUT")
+	}
+}
+
+// call calls fn, with a panic turned into a "!PANIC: " value.
+func (f computedField) call() (value any) {
+	defer func() {
+		if r := recover(); r != nil {
+			value = fmt.Sprintf("!PANIC: %v", r)
+		}
+	}()
+	return f.fn()
+}
+
+// lastOr returns the last value computed, or marker if there is none.
+// It must be called with the lock held.
+func (s *computedState) lastOr(marker string) any {
+	if s.hasLast {
+		return s.last
+	}
+	return marker
+}
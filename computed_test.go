@@ -0,0 +1,119 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestAddComputed(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	var depth atomic.Int64
+	ctx := yasctx.AddComputed(context.Background(), "queue_depth", func() any { return depth.Load() })
+	ctx = yasctx.AddComputed(ctx, "broken", func() any { panic("oops") })
+
+	depth.Store(3)
+	l.InfoContext(ctx, "first")
+	depth.Store(5)
+	l.InfoContext(ctx, "second")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first queue_depth=3 broken="!PANIC: oops"
+time=2023-09-29T13:00:59.000Z level=INFO msg=second queue_depth=5 broken="!PANIC: oops"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestAddComputedConcurrent(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+	ctx := yasctx.AddComputed(context.Background(), "answer", func() any { return 42 })
+
+	// Fast functions are called inline, so concurrent log lines all get a value
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.InfoContext(ctx, "concurrent")
+		}()
+	}
+	wg.Wait()
+
+	for i, r := range tester.Records {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Value.Int64() != 42 {
+				t.Errorf("Expected record %d to have the value; Got: %v", i, a.Value)
+			}
+			return true
+		})
+	}
+}
+
+func TestAddComputedTimeoutBound(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	ctx := yasctx.AddComputed(context.Background(), "slow", func() any {
+		n := calls.Add(1)
+		if n == 1 {
+			time.Sleep(150 * time.Millisecond)
+		}
+		if n == 2 {
+			<-release
+		}
+		return n
+	})
+
+	// The first call is inline, but takes too long, so the next ones are in the background
+	l.InfoContext(ctx, "inline")
+	start := time.Now()
+	l.InfoContext(ctx, "timed out")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected slow computed field to be abandoned; Took: %s", elapsed)
+	}
+
+	// The second call hangs, so the lines logged while it runs reuse the last value
+	for i := 0; i < 20; i++ {
+		l.InfoContext(ctx, "in flight")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected only one call in flight; Got %d calls", n)
+	}
+	for i, r := range tester.Records {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Value.Int64() != 1 {
+				t.Errorf("Expected record %d to have the last value; Got: %v", i, a.Value)
+			}
+			return true
+		})
+	}
+
+	// Once the hanging call finishes, fn is called again
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		l.InfoContext(ctx, "released")
+		time.Sleep(time.Millisecond)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("Expected a new call once released; Got %d calls", n)
+	}
+}
@@ -0,0 +1,68 @@
+package yasctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+type contextIDKey struct{}
+
+// ContextID returns the unique id of the context, assigning a new one (such
+// as "9f86d081884c7d65") on the first call. The id is stored in the returned
+// context, so it is the same for every context derived from it, letting all
+// of their log lines be grouped together even without a tracing system.
+// Calling ContextID again with the returned context (or a context derived
+// from it) returns the same id and the context unchanged.
+// If the context was initialized with InitPropagation, the id is stored in
+// its collector instead, and the context is returned unchanged, so that the
+// parents and children sharing the collector also share the id. Otherwise,
+// callers must use the returned context for the id to be kept.
+// Add ExtractContextID to the HandlerOptions to include the id in log lines.
+func ContextID(parent context.Context) (string, context.Context) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if id, ok := parent.Value(contextIDKey{}).(string); ok {
+		return id, parent
+	}
+
+	if m := fromCtx(parent); m != nil {
+		m.idMu.Lock()
+		defer m.idMu.Unlock()
+		if m.id == "" {
+			m.id = newContextID()
+		}
+		return m.id, parent
+	}
+
+	id := newContextID()
+	return id, context.WithValue(parent, contextIDKey{}, id)
+}
+
+// newContextID returns a new random id.
+func newContextID() string {
+	var b [8]byte
+	// crypto/rand.Read never returns an error on supported platforms
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ExtractContextID is an AttrExtractor that adds a "context_id" attribute with
+// the id assigned by ContextID. Contexts without an id are not assigned one.
+// Add it to the HandlerOptions Prependers or Appenders to use it.
+func ExtractContextID(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if id, ok := ctx.Value(contextIDKey{}).(string); ok {
+		return []slog.Attr{slog.String("context_id", id)}
+	}
+	if m := fromCtx(ctx); m != nil {
+		m.idMu.Lock()
+		defer m.idMu.Unlock()
+		if m.id != "" {
+			return []slog.Attr{slog.String("context_id", m.id)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestContextID(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{yasctx.ExtractContextID},
+	}))
+
+	l.InfoContext(context.Background(), "no id")
+
+	id, parent := yasctx.ContextID(context.Background())
+	if len(id) != 16 {
+		t.Errorf("Expected a 16 character id; Got: %q", id)
+	}
+	child := yasctx.Add(parent, "child", true)
+	childID, same := yasctx.ContextID(child)
+	if childID != id || same != child {
+		t.Errorf("Expected the child to share the id %q; Got: %q", id, childID)
+	}
+
+	otherID, _ := yasctx.ContextID(context.Background())
+	if otherID == id {
+		t.Errorf("Expected a new id for another context; Got: %q", otherID)
+	}
+
+	l.InfoContext(parent, "parent")
+	l.InfoContext(child, "child")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="no id"
+time=2023-09-29T13:00:59.000Z level=INFO msg=parent context_id=` + id + `
+time=2023-09-29T13:00:59.000Z level=INFO msg=child child=true context_id=` + id + `
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestContextIDPropagation(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{yasctx.ExtractContextID},
+	}))
+
+	parent := yasctx.InitPropagation(context.Background())
+	child := yasctx.Add(parent, "child", true)
+
+	// The id is assigned after the child was derived, but stored in the shared collector
+	id, same := yasctx.ContextID(child)
+	if same != child {
+		t.Errorf("Expected the context to be returned unchanged")
+	}
+	if parentID, _ := yasctx.ContextID(parent); parentID != id {
+		t.Errorf("Expected the parent to share the id %q; Got: %q", id, parentID)
+	}
+
+	rebased := yasctx.Rebase(child, context.Background())
+	if rebasedID, _ := yasctx.ContextID(rebased); rebasedID != id {
+		t.Errorf("Expected the rebased context to keep the id %q; Got: %q", id, rebasedID)
+	}
+
+	l.InfoContext(parent, "parent")
+	l.InfoContext(child, "child")
+	l.InfoContext(rebased, "rebased")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=parent context_id=` + id + `
+time=2023-09-29T13:00:59.000Z level=INFO msg=child child=true context_id=` + id + `
+time=2023-09-29T13:00:59.000Z level=INFO msg=rebased child=true context_id=` + id + `
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,60 @@
+package yasctx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// CrashBuffer retains the most recent log lines handled by a Handler, after
+// the attributes from the context have been added, so that they can be dumped
+// when recovering from a panic, even if the log lines were not yet flushed
+// (or were dropped) by the handlers downstream.
+// Set it as HandlerOptions.CrashBuffer to record the log lines.
+// Memory is bounded by the number of log lines retained.
+type CrashBuffer struct {
+	mu      sync.Mutex
+	size    int
+	records []slog.Record
+	next    int // Index of the oldest record, once the buffer is full
+}
+
+// NewCrashBuffer creates a CrashBuffer retaining the most recent size log lines.
+func NewCrashBuffer(size int) *CrashBuffer {
+	size = max(size, 1)
+	return &CrashBuffer{
+		size:    size,
+		records: make([]slog.Record, 0, size),
+	}
+}
+
+// add retains the record, evicting the oldest record if the buffer is full.
+func (b *CrashBuffer) add(r slog.Record) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.records) < b.size {
+		b.records = append(b.records, r)
+		return
+	}
+	b.records[b.next] = r
+	b.next = (b.next + 1) % b.size
+}
+
+// Dump writes the retained log lines to w, from oldest to newest, formatted by
+// slog.TextHandler. The buffer is not cleared.
+func (b *CrashBuffer) Dump(w io.Writer) error {
+	b.mu.Lock()
+	records := make([]slog.Record, 0, len(b.records))
+	records = append(records, b.records[b.next:]...)
+	records = append(records, b.records[:b.next]...)
+	b.mu.Unlock()
+
+	formatter := slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug - 100})
+	for _, r := range records {
+		if err := formatter.Handle(context.Background(), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package yasctx_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestCrashBuffer(t *testing.T) {
+	t.Parallel()
+
+	buffer := yasctx.NewCrashBuffer(3)
+	h := yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{CrashBuffer: buffer}).WithGroup("group1")
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	for i := 1; i <= 5; i++ {
+		r := slog.NewRecord(test.DefaultTime, slog.LevelInfo, fmt.Sprint("step ", i), 0)
+		r.AddAttrs(slog.Int("main1", i))
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected panic")
+			}
+			buf := &bytes.Buffer{}
+			if err := buffer.Dump(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="step 3" request_id=abc group1.main1=3
+time=2023-09-29T13:00:59.000Z level=INFO msg="step 4" request_id=abc group1.main1=4
+time=2023-09-29T13:00:59.000Z level=INFO msg="step 5" request_id=abc group1.main1=5
+`
+			if s := buf.String(); s != expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+			}
+		}()
+		panic("boom")
+	}()
+}
+
+func TestCrashBufferPartial(t *testing.T) {
+	t.Parallel()
+
+	buffer := yasctx.NewCrashBuffer(3)
+	h := yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{CrashBuffer: buffer})
+	if err := h.Handle(yasctx.Add(context.Background(), "key", "value"), slog.NewRecord(test.DefaultTime, slog.LevelDebug, "only", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := buffer.Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "time=2023-09-29T13:00:59.000Z level=DEBUG msg=only key=value\n" {
+		t.Errorf("Unexpected dump: %s", s)
+	}
+}
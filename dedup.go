@@ -0,0 +1,114 @@
+package yasctx
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// dedupAttrs returns the attributes with duplicate keys at the same level
+// collapsed. The last value wins, but keeps the position of the first.
+// Values are resolved first, so that a LogValuer is compared to an eager value
+// by what it resolves to. If both duplicates are groups, they are merged.
+// Groups with an empty key are inlined, as they would be by slog.
+// If joinSep is not empty, duplicate string values are instead joined by it.
+func dedupAttrs(attrs []slog.Attr, joinSep string) []slog.Attr {
+	return collapseAttrs(attrs, joinSep, true)
+}
+
+// compactAttrs is like dedupAttrs, but keeps LogValuers unresolved, so that
+// attributes stored in a context are still only resolved when logged.
+func compactAttrs(attrs []slog.Attr) []slog.Attr {
+	return collapseAttrs(attrs, "", false)
+}
+
+func collapseAttrs(attrs []slog.Attr, joinSep string, resolve bool) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	index := make(map[string]int, len(attrs))
+	for _, a := range inlineAttrs(attrs, resolve) {
+		if a.Value.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(collapseAttrs(a.Value.Group(), joinSep, resolve)...)
+		}
+
+		i, ok := index[a.Key]
+		if !ok {
+			index[a.Key] = len(out)
+			out = append(out, a)
+			continue
+		}
+		if out[i].Value.Kind() == slog.KindGroup && a.Value.Kind() == slog.KindGroup {
+			merged := append(slices.Clip(out[i].Value.Group()), a.Value.Group()...)
+			out[i].Value = slog.GroupValue(collapseAttrs(merged, joinSep, resolve)...)
+			continue
+		}
+		if joinSep != "" && out[i].Value.Kind() == slog.KindString && a.Value.Kind() == slog.KindString {
+			out[i].Value = slog.StringValue(out[i].Value.String() + joinSep + a.Value.String())
+			continue
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// inlineAttrs returns the attributes, with their values resolved if resolve is
+// set, and with the attributes of groups with an empty key inlined into the
+// same level.
+func inlineAttrs(attrs []slog.Attr, resolve bool) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if resolve {
+			a.Value = a.Value.Resolve()
+		}
+		if a.Key == "" && a.Value.Kind() == slog.KindGroup {
+			out = append(out, inlineAttrs(a.Value.Group(), resolve)...)
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// dedupAcrossGroups returns the attributes with only the winning attribute of
+// each key kept, across all levels. The most deeply nested attribute wins, and
+// between attributes at the same depth the last one wins.
+// The attributes must already be deduped at each level by dedupAttrs.
+func dedupAcrossGroups(attrs []slog.Attr) []slog.Attr {
+	type winner struct {
+		depth, index int
+	}
+	winners := map[string]winner{}
+	var index int
+	var find func(attrs []slog.Attr, depth int)
+	find = func(attrs []slog.Attr, depth int) {
+		for _, a := range attrs {
+			if a.Value.Kind() == slog.KindGroup {
+				find(a.Value.Group(), depth+1)
+				continue
+			}
+			if w, ok := winners[a.Key]; !ok || depth >= w.depth {
+				winners[a.Key] = winner{depth: depth, index: index}
+			}
+			index++
+		}
+	}
+	find(attrs, 0)
+
+	index = 0
+	var keep func(attrs []slog.Attr) []slog.Attr
+	keep = func(attrs []slog.Attr) []slog.Attr {
+		out := make([]slog.Attr, 0, len(attrs))
+		for _, a := range attrs {
+			if a.Value.Kind() == slog.KindGroup {
+				if group := keep(a.Value.Group()); len(group) > 0 {
+					out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)})
+				}
+				continue
+			}
+			if winners[a.Key].index == index {
+				out = append(out, a)
+			}
+			index++
+		}
+		return out
+	}
+	return keep(attrs)
+}
@@ -0,0 +1,109 @@
+package yasctx
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+type userValuer struct{}
+
+func (userValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", 24680), slog.String("role", "admin"))
+}
+
+type lazyString string
+
+func (s lazyString) LogValue() slog.Value {
+	return slog.StringValue(string(s))
+}
+
+func TestHandlerDedup(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true}))
+
+	ctx := Add(nil, "user", userValuer{}, "env", lazyString("prod"), "request_id", "abc")
+	ctx = AddToGroup(ctx, "group1", "dup", "ctx")
+
+	l = l.With("env", "test").WithGroup("group1").With("dup", "with")
+	l.InfoContext(ctx, "main message", slog.Group("", "dup", "main"))
+	slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true})).InfoContext(ctx, "eager group", slog.Group("user", "name", "alice", "id", 13579))
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user.id=24680 user.role=admin env=test request_id=abc group1.dup=main
+time=2023-09-29T13:00:59.000Z level=INFO msg="eager group" user.id=13579 user.role=admin user.name=alice env=prod request_id=abc dup=ctx
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerDedupJoinDuplicates(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true, JoinDuplicates: ","}))
+
+	ctx := Add(nil, "tag", "a", "count", 1, "env", lazyString("prod"))
+	ctx = Add(ctx, "tag", "b")
+
+	l.InfoContext(ctx, "main message", "tag", "c", "count", 2, "env", "test", slog.Group("g", "tag", "x"), slog.Group("g", "tag", "y"))
+
+	// Without Dedup, JoinDuplicates does nothing
+	slog.New(NewHandlerWithOptions(tester, &HandlerOptions{JoinDuplicates: ","})).InfoContext(ctx, "no dedup")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" tag=a,b,c count=2 env=prod,test g.tag=x,y
+time=2023-09-29T13:00:59.000Z level=INFO msg="no dedup" tag=a count=1 env=prod tag=b
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerDedupContextOnly(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true, DedupContextOnly: true}))
+
+	ctx := Add(nil, "request_id", "old", "env", "prod")
+	ctx = Add(ctx, "request_id", "new")
+	ctx = AddToGroup(ctx, "group1", "dup", "ctx1", "dup", "ctx2")
+	ctx = AddToGroup(ctx, "orphan", "env", "orphaned")
+
+	l = l.With("with1", "arg1", "with1", "arg2").WithGroup("group1")
+	l.InfoContext(ctx, "main message", "main1", "arg1", "main1", "arg2", "dup", "main")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=new env=orphaned with1=arg1 with1=arg2 group1.dup=ctx2 group1.main1=arg1 group1.main1=arg2 group1.dup=main
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerDedupAcrossGroups(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	scoped := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true}))
+	across := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{Dedup: true, DedupAcrossGroups: true}))
+
+	ctx := Add(nil, "id", "root", "env", "prod")
+	ctx = AddToGroup(ctx, "user", "id", "ctx")
+
+	for _, l := range []*slog.Logger{scoped, across} {
+		l.WithGroup("user").InfoContext(ctx, "main message", "name", "alice", slog.Group("team", "env", "dev"))
+		l.InfoContext(ctx, "same depth", slog.Group("a", "x", 1), slog.Group("b", "x", 2))
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" id=root env=prod user.id=ctx user.name=alice user.team.env=dev
+time=2023-09-29T13:00:59.000Z level=INFO msg="same depth" id=ctx env=prod a.x=1 b.x=2
+time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user.id=ctx user.name=alice user.team.env=dev
+time=2023-09-29T13:00:59.000Z level=INFO msg="same depth" id=ctx env=prod b.x=2
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,74 @@
+package yasctx
+
+import (
+	"context"
+	"log"
+	"log/slog"
+)
+
+// SetDefaultWithContext installs a default logger that logs with ctx whenever
+// a log line is written without a context of its own, so that package level
+// calls such as slog.Info (which pass context.Background) still include the
+// attributes found in ctx. Log lines written with another context, such as
+// with slog.InfoContext, use their own context as usual.
+// The log lines are passed to next, which must include a yasctx Handler for
+// the attributes to be extracted, such as:
+//
+//	yasctx.SetDefaultWithContext(ctx, yasctx.NewHandler(slog.NewJSONHandler(os.Stdout, nil)))
+//
+// next must not be the handler of slog's initial default logger, which writes
+// through the log package, as the log package's output is then redirected to
+// the new default logger.
+// The default logger is global state, shared by all goroutines and packages,
+// so this is best suited to programs with a single long-lived context (such
+// as command line tools and workers), and is not suited to per-request
+// contexts. Calling the returned restore function reinstates the previous
+// default logger, and the previous output and flags of the log package.
+func SetDefaultWithContext(ctx context.Context, next slog.Handler) (restore func()) {
+	previous := slog.Default()
+	w, flags := log.Writer(), log.Flags()
+	slog.SetDefault(slog.New(&boundHandler{next: next, ctx: ctx}))
+	return func() {
+		slog.SetDefault(previous)
+		// Reinstating slog's initial default logger does not undo the redirection of the log package
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	}
+}
+
+// boundHandler is a slog.Handler that uses a bound context for records
+// handled without a context of their own.
+type boundHandler struct {
+	next slog.Handler
+	ctx  context.Context
+}
+
+var _ slog.Handler = &boundHandler{} // Assert conformance with interface
+
+// contextFor returns the bound context if ctx is empty.
+func (h *boundHandler) contextFor(ctx context.Context) context.Context {
+	if ctx == nil || ctx == context.Background() || ctx == context.TODO() {
+		return h.ctx
+	}
+	return ctx
+}
+
+// Enabled reports whether the next handler handles records at the given level.
+func (h *boundHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(h.contextFor(ctx), level)
+}
+
+// Handle passes the record to the next handler, with the bound context if the record has none.
+func (h *boundHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(h.contextFor(ctx), r)
+}
+
+// WithGroup returns a new boundHandler whose next handler has the group.
+func (h *boundHandler) WithGroup(name string) slog.Handler {
+	return &boundHandler{next: h.next.WithGroup(name), ctx: h.ctx}
+}
+
+// WithAttrs returns a new boundHandler whose next handler has the attributes.
+func (h *boundHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &boundHandler{next: h.next.WithAttrs(attrs), ctx: h.ctx}
+}
@@ -0,0 +1,80 @@
+package yasctx_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+// TestSetDefaultWithContext is not parallel, because it modifies the default logger.
+func TestSetDefaultWithContext(t *testing.T) {
+	tester := &test.Handler{}
+	original := slog.Default()
+	slog.SetDefault(slog.New(yasctx.NewHandler(tester)))
+	defer slog.SetDefault(original)
+
+	restore := yasctx.SetDefaultWithContext(yasctx.Add(context.Background(), "worker", "billing"), yasctx.NewHandler(tester))
+	slog.Info("package level", "main1", "arg1")
+	slog.With("with1", "arg1").Info("with attrs")
+	slog.InfoContext(yasctx.Add(context.Background(), "request_id", "abc"), "own context")
+
+	restore()
+	slog.Info("restored")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="package level" worker=billing main1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="with attrs" worker=billing with1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="own context" request_id=abc
+time=2023-09-29T13:00:59.000Z level=INFO msg=restored
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+// TestSetDefaultWithContextBuiltin is not parallel, because it modifies the
+// default logger. It runs with slog's initial default logger, which writes
+// through the log package.
+func TestSetDefaultWithContextBuiltin(t *testing.T) {
+	var buf bytes.Buffer
+	w, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	defer log.SetOutput(w)
+	defer log.SetFlags(flags)
+
+	tester := &test.Handler{}
+	builtin := slog.Default()
+	restore := yasctx.SetDefaultWithContext(yasctx.Add(context.Background(), "worker", "billing"), yasctx.NewHandler(tester))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		slog.Info("package level", "main1", "arg1")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected slog.Info to return")
+	}
+
+	restore()
+	if slog.Default() != builtin || log.Writer() != &buf || log.Flags() != flags {
+		t.Error("Expected the built-in default logger and the log package to be restored")
+	}
+	log.Print("restored")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="package level" worker=billing main1=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if s := strings.TrimSpace(buf.String()); !strings.HasSuffix(s, "restored") || strings.Contains(s, "package level") {
+		t.Errorf("Unexpected log package output:\n%s", buf.String())
+	}
+}
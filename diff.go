@@ -0,0 +1,72 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Diff returns the attributes found in after but not in before (such as those
+// added by an operation, when before is the context it started with).
+// It compares the attributes added with Add, AddWithPropagation, and
+// AddToGroup. Attributes added to a group are returned within that group.
+// An attribute is new if before has no attribute with the same key and value,
+// so an attribute whose value changed is part of the diff.
+func Diff(before, after context.Context) []slog.Attr {
+	var diff []slog.Attr
+	for _, extractor := range []AttrExtractor{extractPropagatedAttrs, extractAdded} {
+		diff = append(diff, diffAttrs(extractor(before, time.Time{}, 0, ""), extractor(after, time.Time{}, 0, ""))...)
+	}
+
+	beforeGroups := extractAddedToGroup(before, time.Time{}, 0, "")
+	afterGroups := extractAddedToGroup(after, time.Time{}, 0, "")
+	groups := make([]string, 0, len(afterGroups))
+	for group := range afterGroups {
+		groups = append(groups, group)
+	}
+	// Sort, so that the output is deterministic
+	slices.Sort(groups)
+	for _, group := range groups {
+		if attrs := diffAttrs(beforeGroups[group], afterGroups[group]); len(attrs) > 0 {
+			diff = append(diff, slog.Attr{Key: group, Value: slog.GroupValue(attrs...)})
+		}
+	}
+	return diff
+}
+
+// LogDiff logs a line with only the attributes added to ctx since before
+// (as reported by Diff), which is useful to log what changed at the boundary
+// of an operation. The line is logged with ctx at the Info level, but with the
+// attributes of Add, AddWithPropagation, and AddToGroup hidden from the
+// handler, so they are not repeated.
+func LogDiff(ctx context.Context, logger *slog.Logger, before context.Context, msg string) {
+	logAttrs(withoutAttrs(ctx), logger, slog.LevelInfo, msg, 3, Diff(before, ctx)...) // Skip runtime.Callers, logAttrs, and LogDiff
+}
+
+// diffAttrs returns the attributes of after that are not in before.
+func diffAttrs(before, after []slog.Attr) []slog.Attr {
+	var diff []slog.Attr
+	for _, a := range after {
+		if !slices.ContainsFunc(before, a.Equal) {
+			diff = append(diff, a)
+		}
+	}
+	return diff
+}
+
+// withoutAttrs returns a context that hides the attributes compared by Diff, while
+// keeping its other values, cancellation, and deadline.
+// The propagation collector is replaced by an empty one sharing its state
+// (such as the ContextID and the SequenceContext counter).
+func withoutAttrs(ctx context.Context) context.Context {
+	if m := fromCtx(ctx); m != nil {
+		ctx = context.WithValue(ctx, ctxKey{}, &syncOrderedMap{kv: map[string]slog.Attr{}, collectorState: m.collectorState})
+	}
+	for _, key := range []any{addKey{}, addToGroupKey{}} {
+		if ctx.Value(key) != nil {
+			ctx = context.WithValue(ctx, key, nil)
+		}
+	}
+	return ctx
+}
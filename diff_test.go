@@ -0,0 +1,68 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestLogDiff(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	before := yasctx.Add(context.Background(), "request_id", "abc", "status", "pending")
+	before = yasctx.AddToGroup(before, "db", "table", "users")
+
+	after := yasctx.Add(before, "status", "done", "rows", 3)
+	after = yasctx.AddToGroup(after, "db", "duration_ms", 12)
+	after = yasctx.AddToGroup(after, "cache", "hit", false)
+
+	yasctx.LogDiff(after, l, before, "operation finished")
+	yasctx.LogDiff(before, l, before, "no changes")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="operation finished" status=done rows=3 cache.hit=false db.duration_ms=12
+time=2023-09-29T13:00:59.000Z level=INFO msg="no changes"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// The source is the caller, not this package
+	for _, r := range tester.Records {
+		if fn := recordFunction(r); fn != "github.com/pazams/yasctx_test.TestLogDiff" {
+			t.Errorf("Expected the caller as the source; Got: %q", fn)
+		}
+	}
+}
+
+func TestLogDiffCollectorState(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{yasctx.ExtractContextID},
+		Sequence:   yasctx.SequenceContext,
+	}))
+
+	before := yasctx.AddWithPropagation(yasctx.InitPropagation(context.Background()), "request_id", "abc")
+	id, before := yasctx.ContextID(before)
+	after := yasctx.Add(before, "status", "done")
+
+	l.InfoContext(before, "started")
+	yasctx.LogDiff(after, l, before, "operation finished")
+	l.InfoContext(after, "finished")
+
+	// The propagated attributes are hidden, but not the id or the sequence
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=started request_id=abc seq=1 context_id=` + id + `
+time=2023-09-29T13:00:59.000Z level=INFO msg="operation finished" seq=2 context_id=` + id + ` status=done
+time=2023-09-29T13:00:59.000Z level=INFO msg=finished request_id=abc status=done seq=3 context_id=` + id + `
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,69 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Dynamic is a registry of attributes that can be updated at runtime, without
+// rebuilding any handlers (such as the current deployment color, or whether
+// this instance is the leader).
+// Add its Extractor to the HandlerOptions Prependers or Appenders to include
+// the current attributes on every log line.
+// Reads are lock-free, so the registry can be read in the hot path of every log.
+// The zero value is ready to use. A Dynamic must not be copied after first use.
+type Dynamic struct {
+	mu    sync.Mutex                  // serializes writers
+	attrs atomic.Pointer[[]slog.Attr] // immutable snapshot, replaced on every write
+}
+
+// Set adds or replaces the attribute with the given key.
+// New keys are added to the end, while existing keys keep their position.
+func (d *Dynamic) Set(key string, value any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	attrs := d.load()
+	i := slices.IndexFunc(attrs, func(a slog.Attr) bool { return a.Key == key })
+	if i < 0 {
+		attrs = append(slices.Clip(attrs), slog.Any(key, value))
+	} else {
+		attrs = slices.Clone(attrs)
+		attrs[i] = slog.Any(key, value)
+	}
+	d.attrs.Store(&attrs)
+}
+
+// Delete removes the attribute with the given key, if present.
+func (d *Dynamic) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	attrs := d.load()
+	i := slices.IndexFunc(attrs, func(a slog.Attr) bool { return a.Key == key })
+	if i < 0 {
+		return
+	}
+	attrs = slices.Delete(slices.Clone(attrs), i, i+1)
+	d.attrs.Store(&attrs)
+}
+
+// Extractor returns an AttrExtractor that adds the current attributes of the registry.
+func (d *Dynamic) Extractor() AttrExtractor {
+	return func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+		return d.load()
+	}
+}
+
+// load returns the current snapshot.
+// The returned slice should not be appended to or modified in any way.
+func (d *Dynamic) load() []slog.Attr {
+	if p := d.attrs.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
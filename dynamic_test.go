@@ -0,0 +1,72 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestDynamic(t *testing.T) {
+	t.Parallel()
+
+	dynamic := &yasctx.Dynamic{}
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{dynamic.Extractor()},
+	}))
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+
+	l.InfoContext(ctx, "empty")
+
+	dynamic.Set("color", "blue")
+	dynamic.Set("leader", false)
+	l.InfoContext(ctx, "set")
+
+	dynamic.Set("color", "green")
+	dynamic.Set("leader", true)
+	l.InfoContext(ctx, "updated")
+
+	dynamic.Delete("color")
+	dynamic.Delete("missing")
+	l.InfoContext(ctx, "deleted")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=empty request_id=abc
+time=2023-09-29T13:00:59.000Z level=INFO msg=set request_id=abc color=blue leader=false
+time=2023-09-29T13:00:59.000Z level=INFO msg=updated request_id=abc color=green leader=true
+time=2023-09-29T13:00:59.000Z level=INFO msg=deleted request_id=abc leader=true
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestDynamicConcurrent(t *testing.T) {
+	t.Parallel()
+
+	dynamic := &yasctx.Dynamic{}
+	l := slog.New(yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{dynamic.Extractor()},
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				dynamic.Set("writer", i)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info("reader")
+			}
+		}()
+	}
+	wg.Wait()
+}
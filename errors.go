@@ -0,0 +1,199 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type firstErrorKey struct{}
+type stackKey struct{}
+type errorKey struct{}
+
+// maxStackDepth bounds the number of frames captured by WithStack.
+const maxStackDepth = 32
+
+// errorStack is an error and the stack trace at the point it was added to the context.
+type errorStack struct {
+	err    error
+	frames []string
+}
+
+// FirstErrorOnly returns a context in which only the first log line with an
+// error attribute keeps it. Once an error attribute has been logged using the
+// returned context (or any context derived from it), error attributes on
+// future log lines are omitted, to avoid repeating the same failure as it is
+// returned up the stack.
+// The first log line keeps its error attributes in every Handler it is passed
+// to (such as the sinks of a fanout).
+func FirstErrorOnly(parent context.Context) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, firstErrorKey{}, &firstError{})
+}
+
+// firstError records the first record logged with an error attribute, so that
+// every Handler given that record (such as behind a fanout, or as a Tee) keeps
+// its error attributes, while removing them from all other records.
+type firstError struct {
+	mu     sync.Mutex
+	logged bool
+	time   time.Time
+	msg    string
+	pc     uintptr
+}
+
+// isFirst reports whether the record is the first one with an error
+// attribute, recording it as such if none was logged yet.
+func (f *firstError) isFirst(r slog.Record) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.logged {
+		f.logged = true
+		f.time, f.msg, f.pc = r.Time, r.Message, r.PC
+		return true
+	}
+	return r.Time.Equal(f.time) && r.Message == f.msg && r.PC == f.pc
+}
+
+// suppressRepeatedErrors removes error attributes if FirstErrorOnly is used and
+// an error attribute has already been logged by another record.
+func suppressRepeatedErrors(ctx context.Context, r slog.Record, attrs []slog.Attr) []slog.Attr {
+	first, ok := ctx.Value(firstErrorKey{}).(*firstError)
+	if !ok || !hasErrorAttr(attrs) || first.isFirst(r) {
+		return attrs
+	}
+	return removeErrorAttrs(attrs)
+}
+
+// isErrorAttr reports whether the attribute's value is an error.
+func isErrorAttr(a slog.Attr) bool {
+	if a.Value.Kind() != slog.KindAny {
+		return false
+	}
+	_, ok := a.Value.Any().(error)
+	return ok
+}
+
+// hasErrorAttr reports whether any attribute, including those in groups, is an error.
+func hasErrorAttr(attrs []slog.Attr) bool {
+	for _, a := range attrs {
+		if isErrorAttr(a) || (a.Value.Kind() == slog.KindGroup && hasErrorAttr(a.Value.Group())) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeErrorAttrs returns a copy of attrs with all error attributes removed, including those in groups.
+func removeErrorAttrs(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if isErrorAttr(a) {
+			continue
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(removeErrorAttrs(a.Value.Group())...)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// WithStack captures the stack trace of the caller, bounded to 32 frames, and
+// stores it in the returned context along with the error.
+// Error level (and above) log lines using the context will include it as a
+// "stacktrace" attribute, with one "function file:line" string per frame.
+// If err is nil, the parent context is returned unchanged.
+func WithStack(parent context.Context, err error) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if err == nil {
+		return parent
+	}
+
+	stack := &errorStack{err: err, frames: callerFrames(3)} // Skip runtime.Callers, callerFrames, and WithStack
+	return withFeature(context.WithValue(parent, stackKey{}, stack), featureStack)
+}
+
+// callerFrames returns the stack trace, bounded to 32 frames, as one
+// "function file:line" string per frame, skipping the frames of the runtime
+// package (such as those of a panic) and the first skip frames.
+func callerFrames(skip int) []string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, frame.Function+" "+frame.File+":"+strconv.Itoa(frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// RecoverAndLog recovers from a panic, and logs the panic value and its stack
+// trace at the Error level, with all the attributes of the context attached.
+// The source of the log line is where the panic happened.
+// It must be called directly by defer:
+//
+//	defer yasctx.RecoverAndLog(ctx, logger, false)
+//
+// If repanic is set, the panic continues after being logged, otherwise it is
+// swallowed. If there is no panic, nothing is logged.
+func RecoverAndLog(ctx context.Context, logger *slog.Logger, repanic bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	// The source is where the panic happened, as the frames of the runtime package are skipped
+	logAttrs(ctx, logger, slog.LevelError, "recovered panic", 3, // Skip runtime.Callers, logAttrs, and RecoverAndLog
+		slog.Any("panic", r),
+		slog.Any("stacktrace", callerFrames(3)), // Skip runtime.Callers, callerFrames, and RecoverAndLog
+	)
+	if repanic {
+		panic(r)
+	}
+}
+
+// extractStack returns the stack trace stored by WithStack, for error level log lines.
+func extractStack(ctx context.Context, _ time.Time, recordLvl slog.Level, _ string) []slog.Attr {
+	if recordLvl < slog.LevelError {
+		return nil
+	}
+	if stack, ok := ctx.Value(stackKey{}).(*errorStack); ok {
+		return []slog.Attr{slog.Any("stacktrace", stack.frames)}
+	}
+	return nil
+}
+
+// WithError marks the returned context as carrying an error (such as one that
+// will be returned up the stack), which causes the extractors in
+// HandlerOptions.DiagnosticExtractors to run for all log lines using it.
+// The error itself is not added to log lines.
+func WithError(parent context.Context, err error) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, errorKey{}, err)
+}
+
+// hasCtxError reports whether the context carries an error, set by WithError or WithStack.
+func hasCtxError(ctx context.Context) bool {
+	if err, ok := ctx.Value(errorKey{}).(error); ok && err != nil {
+		return true
+	}
+	_, ok := ctx.Value(stackKey{}).(*errorStack)
+	return ok
+}
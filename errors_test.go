@@ -0,0 +1,195 @@
+package yasctx_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestFirstErrorOnly(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+	err := errors.New("connection refused")
+
+	ctx := yasctx.FirstErrorOnly(context.Background())
+	ctx = yasctx.Add(ctx, "request_id", "abc")
+
+	l.InfoContext(ctx, "no error yet")
+	l.ErrorContext(yasctx.Add(ctx, "query", "select"), "db failed", "err", err)
+	l.ErrorContext(ctx, "service failed", "err", err, slog.Group("details", "cause", err, "retry", false))
+	l.ErrorContext(context.Background(), "other request failed", "err", err)
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="no error yet" request_id=abc
+time=2023-09-29T13:00:59.000Z level=ERROR msg="db failed" request_id=abc query=select err="connection refused"
+time=2023-09-29T13:00:59.000Z level=ERROR msg="service failed" request_id=abc details.retry=false
+time=2023-09-29T13:00:59.000Z level=ERROR msg="other request failed" err="connection refused"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestFirstErrorOnlyFanout(t *testing.T) {
+	t.Parallel()
+
+	tester1 := &test.Handler{}
+	tester2 := &test.Handler{}
+	l := slog.New(fanout{yasctx.NewHandler(tester1), yasctx.NewHandler(tester2)})
+	err := errors.New("connection refused")
+
+	ctx := yasctx.FirstErrorOnly(context.Background())
+	l.ErrorContext(ctx, "db failed", "err", err)
+	l.ErrorContext(ctx, "db failed", "err", err)
+
+	// Every sink keeps the error of the first line only
+	expected := `time=2023-09-29T13:00:59.000Z level=ERROR msg="db failed" err="connection refused"
+time=2023-09-29T13:00:59.000Z level=ERROR msg="db failed"
+`
+	for i, tester := range []*test.Handler{tester1, tester2} {
+		if s := tester.String(); s != expected {
+			t.Errorf("Sink %d expected:\n%s\nGot:\n%s\n", i, expected, s)
+		}
+	}
+}
+
+func TestWithStack(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := yasctx.WithStack(context.Background(), errors.New("boom"))
+	l.WarnContext(ctx, "not an error")
+	l.ErrorContext(ctx, "an error")
+
+	if n := tester.Records[0].NumAttrs(); n != 0 {
+		t.Errorf("Expected no stacktrace below error level; Got %d attributes", n)
+	}
+
+	var frames []string
+	tester.Records[1].Attrs(func(a slog.Attr) bool {
+		if a.Key == "stacktrace" {
+			frames, _ = a.Value.Any().([]string)
+		}
+		return true
+	})
+	if len(frames) == 0 || len(frames) > 32 {
+		t.Fatalf("Expected between 1 and 32 frames; Got: %v", frames)
+	}
+	if !strings.HasPrefix(frames[0], "github.com/pazams/yasctx_test.TestWithStack ") || !strings.Contains(frames[0], "errors_test.go:") {
+		t.Errorf("Expected first frame to be the caller of WithStack; Got: %s", frames[0])
+	}
+
+	if ctx := yasctx.WithStack(context.Background(), nil); ctx != context.Background() {
+		t.Error("Expected nil error to leave the context unchanged")
+	}
+}
+
+func TestDiagnosticExtractors(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		DiagnosticExtractors: []yasctx.AttrExtractor{
+			func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+				calls++
+				return []slog.Attr{slog.Int("goroutines", 42)}
+			},
+		},
+	}))
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	l.InfoContext(ctx, "happy path")
+	l.ErrorContext(ctx, "error level")
+	l.WarnContext(yasctx.WithError(ctx, errors.New("timeout")), "carries error")
+	l.WarnContext(yasctx.WithError(ctx, nil), "nil error")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="happy path" request_id=abc
+time=2023-09-29T13:00:59.000Z level=ERROR msg="error level" request_id=abc goroutines=42
+time=2023-09-29T13:00:59.000Z level=WARN msg="carries error" request_id=abc goroutines=42
+time=2023-09-29T13:00:59.000Z level=WARN msg="nil error" request_id=abc
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if calls != 2 {
+		t.Errorf("Expected diagnostics to only run for failures; Got %d calls", calls)
+	}
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+
+	func() {
+		defer yasctx.RecoverAndLog(ctx, l, false)
+		panicky()
+	}()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "again" {
+				t.Errorf("Expected re-panic; Got: %v", r)
+			}
+		}()
+		defer yasctx.RecoverAndLog(ctx, l, true)
+		panic("again")
+	}()
+
+	func() {
+		defer yasctx.RecoverAndLog(ctx, l, false)
+	}()
+
+	if len(tester.Records) != 2 {
+		t.Fatalf("Expected 2 records; Got: %d", len(tester.Records))
+	}
+	r := tester.Records[0]
+	if r.Level != slog.LevelError || r.Message != "recovered panic" {
+		t.Errorf("Unexpected record: %v %s", r.Level, r.Message)
+	}
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	if v := attrs["request_id"]; v.String() != "abc" {
+		t.Errorf("Expected context attribute; Got: %v", attrs)
+	}
+	if v := attrs["panic"]; v.String() != "index out of range" {
+		t.Errorf("Expected panic value; Got: %v", v)
+	}
+	frames, _ := attrs["stacktrace"].Any().([]string)
+	if len(frames) == 0 || !strings.HasPrefix(frames[0], "github.com/pazams/yasctx_test.panicky ") {
+		t.Errorf("Expected first frame to be the panicking function; Got: %v", frames)
+	}
+
+	// The source is where the panic happened, not this package
+	for i, expected := range []string{"github.com/pazams/yasctx_test.panicky", "github.com/pazams/yasctx_test.TestRecoverAndLog.func2"} {
+		if fn := recordFunction(tester.Records[i]); fn != expected {
+			t.Errorf("Expected source %q; Got: %q", expected, fn)
+		}
+	}
+}
+
+// recordFunction returns the name of the function at the source of the record.
+func recordFunction(r slog.Record) string {
+	frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+	return frame.Function
+}
+
+func panicky() {
+	panic("index out of range")
+}
@@ -0,0 +1,68 @@
+package yasctx
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// maxExemplarRunes is the OpenMetrics limit on the combined length of the
+// names and values of the labels of an exemplar.
+const maxExemplarRunes = 128
+
+// ExemplarObserver records a metric observation with exemplar labels (such as
+// a Prometheus histogram). It keeps the types of metrics clients out of this
+// package.
+type ExemplarObserver interface {
+	ObserveWithExemplar(value float64, labels map[string]string)
+}
+
+// ExemplarObserverFunc adapts a function to an ExemplarObserver, such as for
+// a Prometheus histogram:
+//
+//	yasctx.ExemplarObserverFunc(func(value float64, labels map[string]string) {
+//		hist.(prometheus.ExemplarObserver).ObserveWithExemplar(value, labels)
+//	})
+type ExemplarObserverFunc func(value float64, labels map[string]string)
+
+// ObserveWithExemplar calls fn.
+func (fn ExemplarObserverFunc) ObserveWithExemplar(value float64, labels map[string]string) {
+	fn(value, labels)
+}
+
+// ExemplarLabels returns the values of the context attributes with the keys
+// (such as "trace_id"), formatted as OpenMetrics exemplar labels, so that a
+// metric observation carries the same correlation ids as the log lines.
+// The values are looked up by key among the attributes added with Add and
+// AddWithPropagation. Keys must be valid label names, and those missing from
+// the context are omitted. Labels that would exceed the OpenMetrics limit of
+// 128 runes for the whole set are omitted, in the order of keys.
+// It returns nil if no labels are found.
+func ExemplarLabels(ctx context.Context, keys ...string) map[string]string {
+	found := ctxAttrsByKey(ctx, time.Time{}, 0, "")
+	var labels map[string]string
+	runes := 0
+	for _, key := range keys {
+		v, ok := found[key]
+		if !ok {
+			continue
+		}
+		value := v.Resolve().String()
+		n := utf8.RuneCountInString(key) + utf8.RuneCountInString(value)
+		if runes+n > maxExemplarRunes {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string, len(keys))
+		}
+		labels[key] = value
+		runes += n
+	}
+	return labels
+}
+
+// ObserveWithExemplar records the value with o, with the exemplar labels of
+// the context attributes with the keys, as returned by ExemplarLabels.
+func ObserveWithExemplar(ctx context.Context, o ExemplarObserver, value float64, keys ...string) {
+	o.ObserveWithExemplar(value, ExemplarLabels(ctx, keys...))
+}
@@ -0,0 +1,49 @@
+package yasctx_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+)
+
+func TestExemplarLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx = yasctx.Add(ctx, "span_id", "00f067aa0ba902b7", "user_id", 42, "large", strings.Repeat("x", 100))
+
+	labels := yasctx.ExemplarLabels(ctx, "trace_id", "span_id", "missing", "large", "user_id")
+	expected := map[string]string{
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+		"user_id":  "42",
+	}
+	if !maps.Equal(labels, expected) {
+		t.Errorf("Expected: %v; Got: %v", expected, labels)
+	}
+
+	if labels := yasctx.ExemplarLabels(context.Background(), "trace_id"); labels != nil {
+		t.Errorf("Expected no labels; Got: %v", labels)
+	}
+}
+
+func TestObserveWithExemplar(t *testing.T) {
+	t.Parallel()
+
+	var observed []string
+	o := yasctx.ExemplarObserverFunc(func(value float64, labels map[string]string) {
+		observed = append(observed, fmt.Sprint(value, labels))
+	})
+
+	ctx := yasctx.Add(context.Background(), "trace_id", "abc", "tenant", "acme")
+	yasctx.ObserveWithExemplar(ctx, o, 0.25, "trace_id")
+
+	if len(observed) != 1 || observed[0] != "0.25 map[trace_id:abc]" {
+		t.Errorf("Expected one observation with the trace id; Got: %v", observed)
+	}
+}
@@ -0,0 +1,109 @@
+package yasctx
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxExpvarFlows is the number of flows kept by an ExpvarRecorder.
+const maxExpvarFlows = 100
+
+// ExpvarRecorder publishes the context attributes of the most recent log lines
+// of each flow to expvar, so that operators can inspect live request state
+// through the debug endpoint (/debug/vars).
+// A flow is named by WithName, and log lines using a context without a name
+// belong to the "" flow.
+// Flow names should come from a small fixed set (such as the handler or job
+// names), not from request data. At most 100 flows are kept, and the log lines
+// of any further flows are not recorded, bounding the memory used.
+// Add its Extractor to the HandlerOptions Prependers or Appenders to record
+// the log lines.
+type ExpvarRecorder struct {
+	mu    sync.Mutex
+	size  int
+	flows map[string]*expvarRing
+}
+
+// expvarRing is a ring buffer of the context attributes of recent log lines.
+type expvarRing struct {
+	entries []map[string]any
+	next    int // Index of the oldest entry, once the ring is full
+}
+
+// NewExpvarRecorder creates an ExpvarRecorder keeping the most recent size
+// log lines of each flow, and publishes it to expvar under name.
+// Like expvar.Publish, it panics if name is already published.
+func NewExpvarRecorder(name string, size int) *ExpvarRecorder {
+	rec := &ExpvarRecorder{
+		size:  max(size, 1),
+		flows: map[string]*expvarRing{},
+	}
+	expvar.Publish(name, expvar.Func(rec.snapshot))
+	return rec
+}
+
+// Extractor returns an AttrExtractor that records the attributes added with
+// Add and AddWithPropagation. It does not add any attributes to the log line.
+func (rec *ExpvarRecorder) Extractor() AttrExtractor {
+	return func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr {
+		attrs := extractPropagatedAttrs(ctx, recordT, recordLvl, recordMsg)
+		entry := attrsToMap(append(attrs, extractAdded(ctx, recordT, recordLvl, recordMsg)...))
+		flow := nameFromCtx(ctx)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		ring, ok := rec.flows[flow]
+		if !ok {
+			if len(rec.flows) >= maxExpvarFlows {
+				return nil
+			}
+			ring = &expvarRing{entries: make([]map[string]any, 0, rec.size)}
+			rec.flows[flow] = ring
+		}
+		if len(ring.entries) < rec.size {
+			ring.entries = append(ring.entries, entry)
+		} else {
+			ring.entries[ring.next] = entry
+			ring.next = (ring.next + 1) % rec.size
+		}
+		return nil
+	}
+}
+
+// snapshot returns the recent entries of each flow, from oldest to newest.
+func (rec *ExpvarRecorder) snapshot() any {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	flows := make(map[string][]map[string]any, len(rec.flows))
+	for flow, ring := range rec.flows {
+		entries := make([]map[string]any, 0, len(ring.entries))
+		entries = append(entries, ring.entries[ring.next:]...)
+		flows[flow] = append(entries, ring.entries[:ring.next]...)
+	}
+	return flows
+}
+
+// attrsToMap converts the attributes to a map suitable for encoding as JSON,
+// with groups as nested maps. Later attributes win.
+func attrsToMap(attrs []slog.Attr) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindGroup:
+			m[a.Key] = attrsToMap(v.Group())
+		case slog.KindAny:
+			if err, ok := v.Any().(error); ok {
+				m[a.Key] = err.Error()
+			} else {
+				m[a.Key] = v.Any()
+			}
+		default:
+			m[a.Key] = v.Any()
+		}
+	}
+	return m
+}
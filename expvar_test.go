@@ -0,0 +1,88 @@
+package yasctx_test
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestExpvarRecorder(t *testing.T) {
+	t.Parallel()
+
+	rec := yasctx.NewExpvarRecorder("yasctx_test_recent", 2)
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Appenders: []yasctx.AttrExtractor{rec.Extractor()},
+	}))
+
+	checkout := yasctx.WithName(context.Background(), "checkout")
+	for i := 1; i <= 3; i++ {
+		l.InfoContext(yasctx.Add(checkout, "request_id", fmt.Sprint(i), slog.Group("user", "id", i)), "request")
+	}
+	l.InfoContext(yasctx.Add(context.Background(), "job", "cleanup"), "unnamed")
+
+	var got map[string][]map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("yasctx_test_recent").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the 2 most recent of each flow are kept, from oldest to newest
+	expected := map[string][]map[string]any{
+		"checkout": {
+			{"request_id": "2", "user": map[string]any{"id": 2.0}},
+			{"request_id": "3", "user": map[string]any{"id": 3.0}},
+		},
+		"": {
+			{"job": "cleanup"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v\n", expected, got)
+	}
+
+	// The recorder adds nothing to the log lines
+	expectedLog := `time=2023-09-29T13:00:59.000Z level=INFO msg=request request_id=1 user.id=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=request request_id=2 user.id=2
+time=2023-09-29T13:00:59.000Z level=INFO msg=request request_id=3 user.id=3
+time=2023-09-29T13:00:59.000Z level=INFO msg=unnamed job=cleanup
+`
+	if s := tester.String(); s != expectedLog {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expectedLog, s)
+	}
+}
+
+func TestExpvarRecorderMaxFlows(t *testing.T) {
+	t.Parallel()
+
+	rec := yasctx.NewExpvarRecorder("yasctx_test_max_flows", 1)
+	l := slog.New(yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{
+		Appenders: []yasctx.AttrExtractor{rec.Extractor()},
+	}))
+
+	for i := 0; i < 150; i++ {
+		l.InfoContext(yasctx.WithName(context.Background(), fmt.Sprint("flow", i)), "request")
+	}
+
+	var got map[string][]map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("yasctx_test_max_flows").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first 100 flows are kept
+	if len(got) != 100 {
+		t.Errorf("Expected 100 flows; Got %d", len(got))
+	}
+	if _, ok := got["flow99"]; !ok {
+		t.Errorf("Expected flow99 to be kept")
+	}
+	if _, ok := got["flow100"]; ok {
+		t.Errorf("Expected flow100 to be dropped")
+	}
+}
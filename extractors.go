@@ -0,0 +1,102 @@
+package yasctx
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/pazams/yasctx/internal/attr"
+)
+
+// StaticExtractor returns an AttrExtractor that always adds the same
+// attribute arguments (such as the service name or version).
+func StaticExtractor(args ...any) AttrExtractor {
+	attrs := attr.ArgsToAttrSlice(args)
+	return func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+		return attrs
+	}
+}
+
+// DerivedExtractor returns an AttrExtractor that computes a single attribute
+// from the values of several attributes found in the context (such as
+// combining a tenant and a region into a shard id).
+// The values are looked up by key among the attributes added with Add and
+// AddWithPropagation, and are passed to fn in the same order as keys.
+// If any of the keys is missing from the context, no attribute is emitted.
+func DerivedExtractor(keys []string, fn func(values []any) slog.Attr) AttrExtractor {
+	return func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr {
+		found := ctxAttrsByKey(ctx, recordT, recordLvl, recordMsg)
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			v, ok := found[key]
+			if !ok {
+				return nil
+			}
+			values[i] = v.Resolve().Any()
+		}
+		return []slog.Attr{fn(values)}
+	}
+}
+
+// FirstNonEmpty returns an AttrExtractor that calls each of the extractors in
+// order, and adds the attributes of the first one that returns any (such as
+// trying OpenTelemetry, then AWS X-Ray, then a custom header, so that trace
+// correlation works across mixed environments).
+func FirstNonEmpty(extractors ...AttrExtractor) AttrExtractor {
+	return func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr {
+		for _, extractor := range extractors {
+			if attrs := extractor(ctx, recordT, recordLvl, recordMsg); len(attrs) > 0 {
+				return attrs
+			}
+		}
+		return nil
+	}
+}
+
+// FingerprintExtractor returns an AttrExtractor that adds a "fingerprint"
+// attribute, a hash of the attributes added with Add and AddWithPropagation,
+// so that log lines of logically identical requests can be grouped together.
+// The fingerprint does not depend on the order the attributes were added in.
+// Attributes whose keys are in exclude (such as volatile timestamps or
+// durations) do not affect the fingerprint.
+func FingerprintExtractor(exclude ...string) AttrExtractor {
+	excluded := make(map[string]bool, len(exclude))
+	for _, key := range exclude {
+		excluded[key] = true
+	}
+	return func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr {
+		found := ctxAttrsByKey(ctx, recordT, recordLvl, recordMsg)
+		keys := make([]string, 0, len(found))
+		for key := range found {
+			if !excluded[key] {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		h := fnv.New64a()
+		for _, key := range keys {
+			// Separate with bytes that are unlikely to appear, so that keys and values can't run together
+			h.Write([]byte(key))
+			h.Write([]byte{0})
+			h.Write([]byte(found[key].Resolve().String()))
+			h.Write([]byte{0})
+		}
+		return []slog.Attr{slog.String("fingerprint", strconv.FormatUint(h.Sum64(), 16))}
+	}
+}
+
+// ctxAttrsByKey returns the root level attributes added with Add and
+// AddWithPropagation, keyed by their attribute key. Later attributes win.
+func ctxAttrsByKey(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) map[string]slog.Value {
+	m := map[string]slog.Value{}
+	for _, extractor := range []AttrExtractor{extractPropagatedAttrs, extractAdded} {
+		for _, a := range extractor(ctx, recordT, recordLvl, recordMsg) {
+			m[a.Key] = a.Value
+		}
+	}
+	return m
+}
@@ -0,0 +1,258 @@
+package yasctx_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestDerivedExtractor(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Appenders: []yasctx.AttrExtractor{
+			yasctx.DerivedExtractor([]string{"tenant", "region"}, func(values []any) slog.Attr {
+				return slog.String("shard", values[0].(string)+"-"+values[1].(string))
+			}),
+		},
+	}))
+
+	ctx := yasctx.Add(context.Background(), "tenant", "acme")
+	l.InfoContext(ctx, "some values")
+
+	ctx = yasctx.AddWithPropagation(ctx, "region", "eu")
+	l.InfoContext(ctx, "all values")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="some values" tenant=acme
+time=2023-09-29T13:00:59.000Z level=INFO msg="all values" tenant=acme region=eu shard=acme-eu
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestStaticExtractor(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		SchemaVersion: "2",
+		Appenders:     []yasctx.AttrExtractor{yasctx.StaticExtractor("service", "api", slog.Int("shard", 3))},
+	}))
+
+	l.InfoContext(yasctx.Add(context.Background(), "request_id", "abc"), "first")
+	l.With("with1", "arg1").Info("second")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first log_schema_version=2 request_id=abc service=api shard=3
+time=2023-09-29T13:00:59.000Z level=INFO msg=second log_schema_version=2 with1=arg1 service=api shard=3
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestFingerprintExtractor(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := func(ctx context.Context) string {
+		attrs := yasctx.FingerprintExtractor("started", "duration")(ctx, test.DefaultTime, slog.LevelInfo, "")
+		if len(attrs) != 1 || attrs[0].Key != "fingerprint" {
+			t.Fatalf("Expected a single fingerprint attribute; Got: %v", attrs)
+		}
+		return attrs[0].Value.String()
+	}
+
+	base := fingerprint(yasctx.Add(context.Background(), "route", "/users", "method", "GET", "started", 1, "duration", "3ms"))
+
+	// Only excluded keys differ, or the order differs
+	for _, ctx := range []context.Context{
+		yasctx.Add(context.Background(), "route", "/users", "method", "GET", "started", 2, "duration", "5ms"),
+		yasctx.Add(context.Background(), "method", "GET", "route", "/users"),
+		yasctx.AddWithPropagation(yasctx.Add(context.Background(), "method", "GET"), "route", "/users"),
+	} {
+		if got := fingerprint(ctx); got != base {
+			t.Errorf("Expected stable fingerprint %s; Got: %s", base, got)
+		}
+	}
+
+	// Included keys differ
+	for _, ctx := range []context.Context{
+		yasctx.Add(context.Background(), "route", "/orders", "method", "GET"),
+		yasctx.Add(context.Background(), "route", "/users"),
+		yasctx.Add(context.Background(), "route", "/users", "method", "GET", "tenant", "acme"),
+	} {
+		if got := fingerprint(ctx); got == base {
+			t.Errorf("Expected different fingerprint than %s", base)
+		}
+	}
+}
+
+func TestSourcelessExtractors(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	h := yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers:           []yasctx.AttrExtractor{yasctx.StaticExtractor("service", "api")},
+		SourcelessExtractors: []yasctx.AttrExtractor{yasctx.StaticExtractor("component", "billing")},
+	})
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+
+	// The logger records the PC of the caller
+	slog.New(h).InfoContext(ctx, "with source")
+
+	if err := h.Handle(ctx, slog.NewRecord(test.DefaultTime, slog.LevelInfo, "without source", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="with source" request_id=abc service=api
+time=2023-09-29T13:00:59.000Z level=INFO msg="without source" request_id=abc service=api component=billing
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestEmptyAttrs(t *testing.T) {
+	t.Parallel()
+
+	extractor := func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+		return []slog.Attr{{}, slog.String("key", "value"), {}}
+	}
+
+	for _, tc := range []struct {
+		keep     bool
+		expected int
+	}{
+		{keep: false, expected: 1},
+		{keep: true, expected: 3},
+	} {
+		tester := &test.Handler{}
+		l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+			Prependers:     []yasctx.AttrExtractor{extractor},
+			Appenders:      []yasctx.AttrExtractor{extractor},
+			KeepEmptyAttrs: tc.keep,
+		}))
+		l.Info("main message")
+
+		var keys []string
+		tester.Records[0].Attrs(func(a slog.Attr) bool {
+			keys = append(keys, a.Key)
+			return true
+		})
+		if len(keys) != 2*tc.expected {
+			t.Errorf("KeepEmptyAttrs %v expected %d attributes; Got: %q", tc.keep, 2*tc.expected, keys)
+		}
+
+		expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" key=value key=value
+`
+		if s := tester.String(); s != expected {
+			t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+		}
+	}
+}
+
+func TestFirstNonEmpty(t *testing.T) {
+	t.Parallel()
+
+	calls := map[string]int{}
+	named := func(name string, attrs ...slog.Attr) yasctx.AttrExtractor {
+		return func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+			calls[name]++
+			return attrs
+		}
+	}
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{
+			yasctx.FirstNonEmpty(
+				named("otel"),
+				named("xray", slog.String("trace_id", "1-5759e988-bd862e3fe1be46a994272793")),
+				named("custom", slog.String("trace_id", "custom")),
+			),
+			yasctx.FirstNonEmpty(named("none")),
+		},
+	}))
+
+	l.Info("main message")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" trace_id=1-5759e988-bd862e3fe1be46a994272793
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if calls["otel"] != 1 || calls["xray"] != 1 || calls["custom"] != 0 || calls["none"] != 1 {
+		t.Errorf("Expected extractors to be tried in order until one yields; Got: %v", calls)
+	}
+}
+
+func TestHandlerLargeExtractorOrderConcurrent(t *testing.T) {
+	t.Parallel()
+
+	// The same slice is returned every time, like StaticExtractor, with spare
+	// capacity so that an append to it in place would be visible to others
+	const n = 100
+	ordered := make([]slog.Attr, n, 2*n)
+	for i := range ordered {
+		ordered[i] = slog.Int(fmt.Sprintf("attr%03d", i), i)
+	}
+	extractor := func(context.Context, time.Time, slog.Level, string) []slog.Attr {
+		return ordered[:n]
+	}
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{extractor},
+		Appenders:  []yasctx.AttrExtractor{extractor},
+	}))
+
+	const goroutines, lines = 8, 50
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			ctx := yasctx.Add(context.Background(), "goroutine", g)
+			gl := l.With("with", g)
+			for i := 0; i < lines; i++ {
+				gl.InfoContext(yasctx.Add(ctx, "line", i), "stress", "record", i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if len(tester.Records) != goroutines*lines {
+		t.Fatalf("Expected %d records; Got: %d", goroutines*lines, len(tester.Records))
+	}
+	for _, r := range tester.Records {
+		var keys []string
+		r.Attrs(func(a slog.Attr) bool {
+			keys = append(keys, a.Key)
+			return true
+		})
+		// The added context, then prepended, then the record, then appended
+		if len(keys) != 2*n+4 {
+			t.Fatalf("Expected %d attributes; Got: %v", 2*n+4, keys)
+		}
+		for i := 0; i < n; i++ {
+			expected := fmt.Sprintf("attr%03d", i)
+			if keys[2+i] != expected || keys[n+4+i] != expected {
+				t.Fatalf("Expected %q at positions %d and %d; Got: %v", expected, 2+i, n+4+i, keys)
+			}
+		}
+		if others := strings.Join(append(keys[:2:2], keys[n+2:n+4]...), ","); others != "goroutine,line,with,record" {
+			t.Fatalf("Expected the context and record attributes around the prepended ones; Got: %v", others)
+		}
+	}
+	if len(ordered) != n || cap(ordered) != 2*n {
+		t.Errorf("Expected the extracted slice to be untouched; Got len %d cap %d", len(ordered), cap(ordered))
+	}
+}
@@ -0,0 +1,143 @@
+package yasctx_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+// fanout is a minimal slog.Handler sending records to several handlers, like slog-multi's Fanout.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithAttrs(attrs)
+	}
+	return f2
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	f2 := make(fanout, len(f))
+	for i, h := range f {
+		f2[i] = h.WithGroup(name)
+	}
+	return f2
+}
+
+// countingExtractor returns an AttrExtractor adding an "extracted" attribute, counting its calls in n.
+func countingExtractor(n *atomic.Int64) yasctx.AttrExtractor {
+	return func(context.Context, time.Time, slog.Level, string) []slog.Attr {
+		n.Add(1)
+		return []slog.Attr{slog.String("extracted", "yes")}
+	}
+}
+
+func TestShareEnrichment(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	ctx = yasctx.AddToGroup(ctx, "req", "path", "/users")
+	logAll := func(l *slog.Logger) {
+		l.InfoContext(ctx, "first", "record", 1)
+		l.With("with", true).WithGroup("req").InfoContext(ctx, "second", "record", 2)
+	}
+
+	// Each sink extracts the context on its own
+	var perSinkCalls atomic.Int64
+	perSink1, perSink2 := &test.Handler{}, &test.Handler{}
+	opts := &yasctx.HandlerOptions{Prependers: []yasctx.AttrExtractor{countingExtractor(&perSinkCalls)}}
+	logAll(slog.New(fanout{
+		yasctx.NewHandlerWithOptions(perSink1, opts),
+		yasctx.NewHandlerWithOptions(perSink2, opts),
+	}))
+
+	// The context is extracted once in front of the fanout, and shared by the sinks
+	var sharedCalls, nestedCalls atomic.Int64
+	shared1, shared2 := &test.Handler{}, &test.Handler{}
+	nestedOpts := &yasctx.HandlerOptions{Prependers: []yasctx.AttrExtractor{countingExtractor(&nestedCalls)}}
+	logAll(slog.New(yasctx.NewHandlerWithOptions(fanout{
+		yasctx.NewHandlerWithOptions(shared1, nestedOpts),
+		yasctx.NewHandlerWithOptions(shared2, nestedOpts),
+	}, &yasctx.HandlerOptions{
+		Prependers:      []yasctx.AttrExtractor{countingExtractor(&sharedCalls)},
+		ShareEnrichment: true,
+	})))
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first request_id=abc extracted=yes path=/users record=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=second request_id=abc extracted=yes with=true req.path=/users req.record=2
+`
+	for name, h := range map[string]*test.Handler{"per sink 1": perSink1, "per sink 2": perSink2, "shared 1": shared1, "shared 2": shared2} {
+		if s := h.String(); s != expected {
+			t.Errorf("%s: Expected:\n%s\nGot:\n%s\n", name, expected, s)
+		}
+	}
+
+	if n := perSinkCalls.Load(); n != 4 {
+		t.Errorf("Expected 4 extractions per sink; Got: %d", n)
+	}
+	if n, nested := sharedCalls.Load(), nestedCalls.Load(); n != 2 || nested != 0 {
+		t.Errorf("Expected 2 shared extractions and none nested; Got: %d and %d", n, nested)
+	}
+}
+
+func BenchmarkShareEnrichment(b *testing.B) {
+	ctx := yasctx.Add(context.Background(), "request_id", "abc", "user_id", 42, "tenant", "acme")
+	const sinks = 4
+
+	for _, share := range []bool{false, true} {
+		name := "per-sink"
+		if share {
+			name = "shared"
+		}
+		b.Run(name, func(b *testing.B) {
+			var calls atomic.Int64
+			opts := &yasctx.HandlerOptions{Prependers: []yasctx.AttrExtractor{countingExtractor(&calls)}}
+			f := make(fanout, sinks)
+			for i := range f {
+				f[i] = yasctx.NewHandlerWithOptions(slog.NewJSONHandler(io.Discard, nil), opts)
+			}
+			var h slog.Handler = f
+			if share {
+				h = yasctx.NewHandlerWithOptions(f, &yasctx.HandlerOptions{
+					Prependers:      opts.Prependers,
+					ShareEnrichment: true,
+				})
+			}
+			l := slog.New(h)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.InfoContext(ctx, "main message", "main1", "arg1")
+			}
+			b.ReportMetric(float64(calls.Load())/float64(b.N), "extractions/op")
+		})
+	}
+}
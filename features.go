@@ -0,0 +1,66 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type featuresKey struct{}
+
+// features is a bit set of the optional context values that add attributes to
+// log lines, so that a record only pays for the lookups of the ones in use.
+type features uint16
+
+const (
+	featureAddedToName features = 1 << iota
+	featureCaller
+	featureTenant
+	featureTTL
+	featureSpans
+	featurePath
+	featureComputed
+	featureStack
+)
+
+// featureExtractors are the extractors of each feature, in the order their
+// attributes are prepended.
+var featureExtractors = []struct {
+	feature   features
+	extractor AttrExtractor
+}{
+	{featureAddedToName, extractAddedToName},
+	{featureCaller, extractCaller},
+	{featureTenant, extractTenant},
+	{featureTTL, extractTTLAttrs},
+	{featureSpans, extractSpanCount},
+	{featurePath, extractPath},
+	{featureComputed, extractComputed},
+	{featureStack, extractStack},
+}
+
+// withFeature marks the feature as in use by the context and its children.
+func withFeature(ctx context.Context, f features) context.Context {
+	v, _ := ctx.Value(featuresKey{}).(features)
+	if v&f != 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, featuresKey{}, v|f)
+}
+
+// extractFeatures returns the attributes of the features in use by the
+// context, with a single lookup when there are none.
+func extractFeatures(ctx context.Context, t time.Time, lvl slog.Level, msg string) []slog.Attr {
+	v, _ := ctx.Value(featuresKey{}).(features)
+	if v == 0 {
+		return nil
+	}
+
+	var attrs []slog.Attr
+	for _, fe := range featureExtractors {
+		if v&fe.feature != 0 {
+			attrs = append(attrs, fe.extractor(ctx, t, lvl, msg)...)
+		}
+	}
+	return attrs
+}
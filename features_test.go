@@ -0,0 +1,24 @@
+package yasctx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func BenchmarkHandlerFeatures(b *testing.B) {
+	for name, ctx := range map[string]context.Context{
+		"none":   Add(nil, "request_id", 1),
+		"tenant": WithTenant(Add(nil, "request_id", 1), "acme"),
+	} {
+		b.Run(name, func(b *testing.B) {
+			l := slog.New(NewHandler(slog.NewJSONHandler(io.Discard, nil)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.InfoContext(ctx, "main message", "main1", "arg1")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type flagsKey struct{}
+
+// WithFlags stores the values of feature flags (such as those evaluated for
+// the current user) in the returned context, merged with any flags stored
+// earlier, with the new values winning.
+// Add a FlagsExtractor to the HandlerOptions to include them in log lines.
+func WithFlags(parent context.Context, flags map[string]any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	// Copy the map, so that the parent context is not modified
+	v, _ := parent.Value(flagsKey{}).(map[string]any)
+	m := make(map[string]any, len(v)+len(flags))
+	for k, value := range v {
+		m[k] = value
+	}
+	for k, value := range flags {
+		m[k] = value
+	}
+	return context.WithValue(parent, flagsKey{}, m)
+}
+
+// FlagsExtractor returns an AttrExtractor that adds a "flags" group, with the
+// values of the named feature flags stored by WithFlags, so that log lines
+// show which flags were active for a request.
+// Only the named flags are included, in the order given. Flags that are not
+// stored in the context are omitted.
+func FlagsExtractor(flags ...string) AttrExtractor {
+	return func(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+		v, ok := ctx.Value(flagsKey{}).(map[string]any)
+		if !ok {
+			return nil
+		}
+		attrs := make([]slog.Attr, 0, len(flags))
+		for _, flag := range flags {
+			if value, ok := v[flag]; ok {
+				attrs = append(attrs, slog.Any(flag, value))
+			}
+		}
+		if len(attrs) == 0 {
+			return nil
+		}
+		return []slog.Attr{{Key: "flags", Value: slog.GroupValue(attrs...)}}
+	}
+}
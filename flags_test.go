@@ -0,0 +1,40 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestFlagsExtractor(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Appenders: []yasctx.AttrExtractor{yasctx.FlagsExtractor("new_checkout", "dark_mode", "beta_api")},
+	}))
+
+	ctx := yasctx.WithFlags(context.Background(), map[string]any{
+		"new_checkout": true,
+		"dark_mode":    false,
+		"unlisted":     "hidden",
+	})
+	child := yasctx.WithFlags(ctx, map[string]any{"beta_api": "v2", "dark_mode": true})
+
+	l.InfoContext(child, "child")
+	l.InfoContext(ctx, "parent")
+	l.InfoContext(context.Background(), "no flags")
+	l.InfoContext(yasctx.WithFlags(context.Background(), map[string]any{"unlisted": 1}), "no listed flags")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=child flags.new_checkout=true flags.dark_mode=true flags.beta_api=v2
+time=2023-09-29T13:00:59.000Z level=INFO msg=parent flags.new_checkout=true flags.dark_mode=false
+time=2023-09-29T13:00:59.000Z level=INFO msg="no flags"
+time=2023-09-29T13:00:59.000Z level=INFO msg="no listed flags"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
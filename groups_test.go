@@ -0,0 +1,231 @@
+package yasctx
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestHandlerFlattenGroups(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		separator string
+		expected  string
+	}{
+		{
+			separator: "",
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1.ctx2=arg1 group1.with1=arg1 group1.main1=arg1 group1.sub.main2=arg1 group1.main3=arg1
+`,
+		},
+		{
+			separator: "_",
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1_ctx2=arg1 group1_with1=arg1 group1_main1=arg1 group1_sub_main2=arg1 group1_main3=arg1
+`,
+		},
+		{
+			separator: "/",
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1/ctx2=arg1 group1/with1=arg1 group1/main1=arg1 group1/sub/main2=arg1 group1/main3=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		h := NewHandlerWithOptions(tester, &HandlerOptions{
+			FlattenGroups:  true,
+			GroupSeparator: tc.separator,
+		})
+
+		ctx := Add(nil, "ctx1", "arg1")
+		ctx = AddToGroup(ctx, "group1", "ctx2", "arg1")
+
+		l := slog.New(h).WithGroup("group1").With("with1", "arg1")
+		l.InfoContext(ctx, "main message", "main1", "arg1", slog.Group("sub", "main2", "arg1"), slog.Group("", "main3", "arg1"))
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("Separator %q expected:\n%s\nGot:\n%s\n", tc.separator, tc.expected, s)
+		}
+	}
+}
+
+func TestHandlerGroupPrefix(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		nested   bool
+		expected string
+	}{
+		{
+			nested: false,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 svc.ctxgroup.inner=arg1 svc.group1.ctx2=arg1 svc.group1.with1=arg1 svc.group1.main1=arg1 svc.group1.sub.main2=arg1 svc.group1.main3=arg1
+`,
+		},
+		{
+			nested: true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 svc.ctxgroup.inner=arg1 svc.group1.ctx2=arg1 svc.group1.with1=arg1 svc.group1.main1=arg1 svc.group1.svc.sub.main2=arg1 svc.group1.main3=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		h := NewHandlerWithOptions(tester, &HandlerOptions{
+			GroupPrefix:       "svc.",
+			GroupPrefixNested: tc.nested,
+		})
+
+		ctx := Add(nil, "ctx1", "arg1", slog.Group("ctxgroup", "inner", "arg1"))
+		ctx = AddToGroup(ctx, "group1", "ctx2", "arg1")
+
+		l := slog.New(h).WithGroup("group1").With("with1", "arg1")
+		l.InfoContext(ctx, "main message", "main1", "arg1", slog.Group("sub", "main2", "arg1"), slog.Group("", "main3", "arg1"))
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("Nested %v expected:\n%s\nGot:\n%s\n", tc.nested, tc.expected, s)
+		}
+	}
+}
+
+func TestHandlerOrphanedGroup(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		orphanedGroup string
+		expected      string
+	}{
+		{
+			orphanedGroup: "",
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 orphan2=arg1 orphan3=arg1 group1.found=arg1 group1.main1=arg1
+`,
+		},
+		{
+			orphanedGroup: "_orphaned",
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 _orphaned.orphan2=arg1 _orphaned.orphan3=arg1 group1.found=arg1 group1.main1=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		h := NewHandlerWithOptions(tester, &HandlerOptions{OrphanedGroup: tc.orphanedGroup})
+
+		ctx := Add(nil, "ctx1", "arg1")
+		ctx = AddToGroup(ctx, "group3", "orphan3", "arg1")
+		ctx = AddToGroup(ctx, "group1", "found", "arg1")
+		ctx = AddToGroup(ctx, "group2", "orphan2", "arg1")
+
+		slog.New(h).WithGroup("group1").InfoContext(ctx, "main message", "main1", "arg1")
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("OrphanedGroup %q expected:\n%s\nGot:\n%s\n", tc.orphanedGroup, tc.expected, s)
+		}
+	}
+}
+
+func TestHandlerGroupAttrPositions(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	h := NewHandlerWithOptions(tester, &HandlerOptions{
+		GroupAttrPositions: map[string]Position{"group2": PositionAfter},
+	})
+
+	ctx := AddToGroup(nil, "group1", "ctx1", "arg1")
+	ctx = AddToGroup(ctx, "group2", "ctx2", "arg1")
+
+	slog.New(h).WithGroup("group1").With("with1", "arg1").WithGroup("group2").InfoContext(ctx, "main message", "main1", "arg1")
+
+	// Same again, but with the global position set to after, and group2 overridden to before
+	h = NewHandlerWithOptions(tester, &HandlerOptions{
+		GroupAttrPosition:  PositionAfter,
+		GroupAttrPositions: map[string]Position{"group2": PositionBefore},
+	})
+	slog.New(h).WithGroup("group1").With("with1", "arg1").WithGroup("group2").InfoContext(ctx, "main message", "main1", "arg1")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" group1.ctx1=arg1 group1.with1=arg1 group1.group2.main1=arg1 group1.group2.ctx2=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="main message" group1.with1=arg1 group1.group2.ctx2=arg1 group1.group2.main1=arg1 group1.ctx1=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerCorrelationGroup(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		CorrelationGroup: "correlation",
+		Appenders:        []AttrExtractor{StaticExtractor("span_id", "00f067aa0ba902b7")},
+	}))
+
+	ctx := InitPropagation(nil)
+	ctx = AddWithPropagation(ctx, "trace_id", "4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx = Add(ctx, "user_id", 24680, "request_id", "abc")
+	ctx = WithTenant(ctx, "acme")
+
+	l.InfoContext(ctx, "main message", "request_id", "from record")
+	l.InfoContext(nil, "nothing to correlate")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" correlation.trace_id=4bf92f3577b34da6a3ce929d0e0e4736 correlation.request_id=abc correlation.tenant_id=acme correlation.span_id=00f067aa0ba902b7 user_id=24680 request_id="from record"
+time=2023-09-29T13:00:59.000Z level=INFO msg="nothing to correlate" correlation.span_id=00f067aa0ba902b7
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	tester.Clear()
+	l = slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		CorrelationGroup: "ids",
+		CorrelationKeys:  []string{"user_id"},
+	}))
+	l.InfoContext(ctx, "custom keys")
+
+	expected = `time=2023-09-29T13:00:59.000Z level=INFO msg="custom keys" ids.user_id=24680 trace_id=4bf92f3577b34da6a3ce929d0e0e4736 request_id=abc tenant_id=acme
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerCollapseIdenticalGroups(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{CollapseIdenticalGroups: true}))
+
+	ctx := Add(nil, slog.Group("request", "actor", "alice", "action", "delete"))
+	l.InfoContext(ctx, "identical",
+		slog.Group("audit", "actor", "alice", "action", "delete"),
+		slog.Group("other", "actor", "bob"),
+		slog.Group("nested", slog.Group("a", "k", 1), slog.Group("b", "k", 1)),
+	)
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=identical request.actor=alice request.action=delete audit=@request other.actor=bob nested.a.k=1 nested.b=@a
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerContextGroup(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		ContextGroupName: "ctx",
+		Appenders:        []AttrExtractor{StaticExtractor("app1", "arg1")},
+	}))
+
+	ctx := Add(nil, "request_id", "abc", "user_id", 24680)
+	ctx = AddToGroup(ctx, "group1", "ctx2", "arg1")
+	l.InfoContext(ctx, "flat", "main1", "arg1")
+	l.WarnContext(ctx, "grouped", "main1", "arg1")
+	l.WithGroup("group1").ErrorContext(ctx, "grouped with group", "main1", "arg1")
+	l.Error("nothing to group")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=flat request_id=abc user_id=24680 ctx2=arg1 main1=arg1 app1=arg1
+time=2023-09-29T13:00:59.000Z level=WARN msg=grouped ctx.request_id=abc ctx.user_id=24680 ctx.ctx2=arg1 main1=arg1 app1=arg1
+time=2023-09-29T13:00:59.000Z level=ERROR msg="grouped with group" ctx.request_id=abc ctx.user_id=24680 group1.ctx2=arg1 group1.main1=arg1 app1=arg1
+time=2023-09-29T13:00:59.000Z level=ERROR msg="nothing to group" app1=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -3,14 +3,17 @@ package yasctx
 import (
 	"context"
 	"log/slog"
+	"reflect"
+	"runtime"
 	"slices"
+	"strings"
 	"time"
 )
 
-// attrExtractor is a function that retrieves or creates slog.Attr's based
+// AttrExtractor is a function that retrieves or creates slog.Attr's based
 // information/values found in the context.Context and the slog.Record's basic
 // attributes.
-type attrExtractor func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr
+type AttrExtractor func(ctx context.Context, recordT time.Time, recordLvl slog.Level, recordMsg string) []slog.Attr
 
 // Handler is a slog.Handler middleware that will Prepend and
 // Append attributes to log lines. The attributes are extracted out of the log
@@ -19,11 +22,335 @@ type attrExtractor func(ctx context.Context, recordT time.Time, recordLvl slog.L
 type Handler struct {
 	next       slog.Handler
 	goa        *groupOrAttrs
-	prependers []attrExtractor
+	prependers []AttrExtractor
+	perLevel   []levelPrependers // Sorted by level
+	appenders  []AttrExtractor
+	transforms map[string]func(slog.Value) slog.Value
+	opts       HandlerOptions
+}
+
+// levelPrependers are the prependers used for records at or above a level.
+type levelPrependers struct {
+	level      slog.Level
+	prependers []AttrExtractor
+}
+
+// Position is where context attributes are placed, relative to the other attributes.
+type Position int
+
+const (
+	// PositionBefore places the context attributes before the other attributes.
+	PositionBefore Position = iota
+
+	// PositionAfter places the context attributes after the other attributes.
+	PositionAfter
+)
+
+// HandlerOptions are options for a Handler
+type HandlerOptions struct {
+	// Prependers are additional AttrExtractors whose attributes are added to the
+	// start of the log line, after the attributes added by this package.
+	Prependers []AttrExtractor
+
+	// PerLevel replaces Prependers with a different set of AttrExtractors for
+	// records at or above each level (such as a minimal set for Info, and a
+	// rich set for Error). A record uses the set of the greatest level that is
+	// not above its own level. If no level matches, Prependers is used.
+	// The attributes added by this package are included regardless.
+	PerLevel map[slog.Level][]AttrExtractor
+
+	// SourcelessExtractors are AttrExtractors whose attributes are added after
+	// those of the Prependers, but only to records without source information
+	// (those whose PC is zero), such as for enrichment that is redundant when
+	// slog.HandlerOptions.AddSource is used.
+	SourcelessExtractors []AttrExtractor
+
+	// DiagnosticExtractors are AttrExtractors whose attributes are added after
+	// those of the Prependers, but only to error level log lines, or to log
+	// lines using a context carrying an error (set by WithError or WithStack).
+	// This attaches expensive debugging context only to failures, sparing the
+	// overhead on the happy path.
+	DiagnosticExtractors []AttrExtractor
+
+	// VerboseExtractors are AttrExtractors whose attributes are added after
+	// those of the Prependers, but only to log lines using a context with
+	// verbose logging turned on by WithVerbose.
+	VerboseExtractors []AttrExtractor
+
+	// Appenders are AttrExtractors whose attributes are added to the end of the
+	// log line, at the root level.
+	Appenders []AttrExtractor
+
+	// Audit, if set (such as to an AuditExtractor), is called for each log line
+	// using a context with attributes added by AddAudit, and its attributes are
+	// added to the end of the log line, after those of the Appenders.
+	// It is called before SampleRate is applied and whatever the level of the
+	// log line, so that no audit entry is lost to log sampling, and only once
+	// per log line, even when several Handlers (such as behind a fanout, or as
+	// a Tee) have it set.
+	Audit AttrExtractor
+
+	// KeepEmptyAttrs causes empty attributes (the zero slog.Attr, with an empty
+	// key and value) returned by extractors or added to the context to be
+	// passed to the next handler, rather than dropped.
+	// slog handlers are expected to ignore them, but not all do.
+	KeepEmptyAttrs bool
+
+	// ReverseAppenders causes the attributes of the Appenders to be added in
+	// the reverse order of their registration.
+	ReverseAppenders bool
+
+	// AppendersBeforeRecord causes the attributes of the Appenders to precede
+	// the attributes of the record (and its groups), following those of the
+	// prependers, rather than being added to the end of the log line.
+	AppendersBeforeRecord bool
+
+	// Dedup causes attributes with duplicate keys at the same level to be
+	// collapsed, with the last value winning. LogValuer values are resolved
+	// before being compared, and duplicate groups are merged.
+	Dedup bool
+
+	// DedupContextOnly limits Dedup to collapsing duplicates between context
+	// attributes, leaving the record's own attributes (and those from WithAttrs)
+	// untouched, for callers that intentionally log duplicate keys.
+	// It has no effect unless Dedup is enabled.
+	DedupContextOnly bool
+
+	// DedupAcrossGroups extends Dedup to collapse attributes with the same key
+	// at different levels (such as "id" at the root level and "user.id"),
+	// which are normally distinct. The most deeply nested attribute wins, as it
+	// is the most specific, and between attributes at the same depth the last
+	// one wins. Groups left empty are removed. Groups themselves are never
+	// collapsed with attributes.
+	// It has no effect unless Dedup is enabled, and DedupContextOnly is not.
+	DedupAcrossGroups bool
+
+	// JoinDuplicates, if set, causes Dedup to join the values of duplicate
+	// string attributes with this separator (such as ","), instead of keeping
+	// only the last one. Duplicates that are not both strings keep the last value.
+	// It has no effect unless Dedup is enabled.
+	JoinDuplicates string
+
+	// SchemaVersion, if set, is added to the start of every log line as a
+	// "log_schema_version" attribute, so that downstream consumers can handle
+	// changes to the format of the log lines over time.
+	SchemaVersion string
+
+	// RequestDuration causes log lines using a context marked by MarkStart to
+	// include a "request_duration" attribute, with the time elapsed since the start.
+	RequestDuration bool
+
+	// TextOutput declares that the next handler writes text meant for humans
+	// (such as slog.TextHandler), rather than JSON, which causes the attributes
+	// added with AddTextOnly to be included in the log lines.
+	// slog does not expose the type of a handler, so it must be declared.
+	TextOutput bool
+
+	// Sequence adds a "seq" attribute to log lines, with a number that
+	// increases by one for each log line, so that consumers can detect dropped
+	// or reordered log lines. The scope of the numbering is either the handler
+	// or the context. Default is SequenceNone.
+	Sequence SequenceScope
+
+	// FlattenGroups causes all groups to be flattened into the root level, with
+	// the group names prefixed to the attribute keys (such as "group1.key1").
+	FlattenGroups bool
+
+	// GroupSeparator is placed between group names and keys when FlattenGroups
+	// is enabled. Default is ".".
+	GroupSeparator string
+
+	// CollapseIdenticalGroups causes a group whose attributes are identical to
+	// those of an earlier group at the same level to be replaced by a reference
+	// to it: a string attribute with the name of the earlier group prefixed by
+	// "@" (such as audit="@request"). This reduces the size of verbose log lines.
+	CollapseIdenticalGroups bool
+
+	// GroupPrefix, if set, is prefixed to the name of every group on the log
+	// line, both those from WithGroup and those from the context, to namespace
+	// the entire output of the handler (such as with the name of the service).
+	// Only top level groups are prefixed, unless GroupPrefixNested is enabled.
+	GroupPrefix string
+
+	// GroupPrefixNested causes GroupPrefix to also be prefixed to the names of
+	// groups nested within other groups.
+	GroupPrefixNested bool
+
+	// GroupAttrPosition is where attributes added with AddToGroup are placed,
+	// relative to the group's other attributes (those from the record and from
+	// WithAttrs). Default is PositionBefore.
+	GroupAttrPosition Position
+
+	// GroupAttrPositions overrides GroupAttrPosition for specific group names.
+	GroupAttrPositions map[string]Position
+
+	// OrphanedGroup is the name of a catch-all group for attributes added with
+	// AddToGroup whose group is not used by the log line, so they are clearly
+	// distinguished from attributes intentionally added to the root level
+	// (such as "_orphaned").
+	// Default is "", which adds them to the root level.
+	OrphanedGroup string
+
+	// StripInternalMarkers removes attributes whose keys start with an
+	// underscore (such as "_truncated" or "_dropped") at any level, just before
+	// the record is passed on. These internal markers help when debugging, but
+	// are noise in production. Groups are kept, with their markers removed.
+	// Default is disabled.
+	StripInternalMarkers bool
+
+	// SampleRate, if between 0 and 1, is the fraction of log lines kept, with
+	// the rest dropped. Log lines using a context with a seed set by
+	// WithSampleSeed make the same decision as all others with that seed.
+	// Default is 0, which keeps all log lines.
+	SampleRate float64
+
+	// KeySampleRates, by key, are the fractions of log lines on which those
+	// context attributes are kept (such as high cardinality ids), with the rest
+	// of the log lines written without them. This controls the cardinality of
+	// metrics derived from logs, while always keeping the other attributes.
+	// Each attribute is sampled independently on each log line. Rates that are
+	// not between 0 and 1 keep the attribute on all log lines, like SampleRate.
+	KeySampleRates map[string]float64
+
+	// CrashBuffer, if set, retains the most recent log lines, with their
+	// context attributes, to be dumped when recovering from a panic.
+	CrashBuffer *CrashBuffer
+
+	// Clock, if set, tells the time passed to the AttrExtractors (such as the
+	// time used for "request_duration" and to expire the attributes of
+	// AddWithTTL), instead of the time of the record.
+	// It lets tests inject a fake clock, along with WithClock.
+	Clock Clock
+
+	// Metrics, if set, receives observations about the log lines, such as the
+	// number of attributes per log line.
+	Metrics Metrics
+
+	// Tee, if set, also receives every log line passed to the next handler,
+	// after the context attributes are added (such as for a sampling or
+	// analytics sink). Errors returned by the tee are ignored.
+	Tee slog.Handler
+
+	// TenantHandlers routes the log lines of each tenant set by WithTenant to
+	// its own handler (such as a per-tenant sink), instead of the next handler.
+	// Log lines without a tenant, or whose tenant has no handler, go to the
+	// next handler.
+	TenantHandlers map[string]slog.Handler
+
+	// ShareEnrichment marks the context passed to the next handler (and the
+	// Tee) as already enriched, so that any Handler nested below it passes the
+	// records on unchanged instead of extracting the context attributes again.
+	// This lets a single Handler in front of a fanout (such as slog-multi's
+	// Fanout) do the work once for all of the sinks, each of which may still
+	// be wrapped by its own Handler for when it is used on its own.
+	// The options of the nested Handlers are not applied to the shared
+	// records, so use it only when they are the same. Nested Handlers given
+	// attributes or groups of their own (with WithAttrs or WithGroup) still
+	// extract the context attributes.
+	// Default is disabled.
+	ShareEnrichment bool
+
+	// AttrLess, if set, orders the context attributes (such as to pin
+	// correlation ids to the front). Attributes are only ordered among the
+	// others from the same set of extractors, or added to the same group, so
+	// they never cross group boundaries. The order of equal attributes is kept.
+	AttrLess func(a, b slog.Attr) bool
+
+	// ContextGroupName, if set, is the name of a group (such as "ctx") in
+	// which the root level attributes from the context and the prependers are
+	// nested, for log lines at or above ContextGroupLevel. This keeps high
+	// volume log lines flat, while nesting the detail of low volume ones.
+	// The attributes of the Appenders are not nested.
+	ContextGroupName string
+
+	// ContextGroupLevel is the minimum level of the log lines whose context
+	// attributes are nested in ContextGroupName. Default is slog.LevelWarn.
+	ContextGroupLevel slog.Leveler
+
+	// CorrelationGroup, if set, is the name of a group (such as "correlation")
+	// into which the root level context attributes with a key in
+	// CorrelationKeys are moved, regardless of which extractor produced them,
+	// so that they are found together at the start of the log line.
+	CorrelationGroup string
+
+	// CorrelationKeys are the keys of the attributes moved into
+	// CorrelationGroup. Default is "trace_id", "span_id", "request_id", and "tenant_id".
+	CorrelationKeys []string
+
+	// RequireKeys are the keys of attributes that every log line must have at
+	// the root level (such as "request_id"), whether from the context or the
+	// record. OnMissingRequired is called for log lines missing any of them.
+	// They are checked before CorrelationGroup and ContextGroupName move the
+	// context attributes into groups.
+	RequireKeys []string
+
+	// OnMissingRequired is called with the keys in RequireKeys that are missing
+	// from a log line (such as to log a warning or increment a metric).
+	// The log line is still passed to the next handler.
+	OnMissingRequired func(missing []string)
+
+	// MessageTransform, if set, lets the context influence the final message of
+	// the log line (such as prefixing it with a tenant tag). It is applied
+	// before the record is passed to the next handler.
+	MessageTransform func(ctx context.Context, msg string) string
+
+	// KeyRemap renames the keys of context attributes (such as from "uid" to
+	// "user_id"), to adapt to a schema without changing the call sites.
+	// ValueTransformers and NormalizeCase apply to the renamed keys.
+	// If a renamed key collides with another key, both are kept, unless Dedup is enabled.
+	KeyRemap map[string]string
+
+	// ValueTransformers transform the values of context attributes, by key
+	// (such as to redact or reformat them).
+	ValueTransformers map[string]func(slog.Value) slog.Value
+
+	// NormalizeCase normalizes the string values of context attributes to a
+	// letter case, by key (such as forcing "env" to lower case).
+	// If a key also has a ValueTransformer, it is applied first.
+	NormalizeCase map[string]Case
+
+	// BytesEncoding is how []byte values of context attributes (including
+	// those in groups) are encoded as strings, so that they are rendered
+	// consistently by all handlers. Default is BytesUnchanged.
+	BytesEncoding BytesEncoding
+
+	// MaxSliceLen, if greater than 0, caps the length of the slice values of
+	// context attributes (such as accumulated ids), keeping only the most
+	// recent (last) elements. When a slice is capped, it is followed by an
+	// attribute keyed by "_" + its key + "_more" (such as "_ids_more"), with
+	// the number of elements dropped. []byte values are not capped.
+	// Default is 0, which emits slices in full.
+	MaxSliceLen int
+
+	// MaxSliceLens overrides MaxSliceLen for specific keys. A length of 0
+	// emits the slices of the key in full.
+	MaxSliceLens map[string]int
+
+	// TypedAttrs causes each context attribute (including those in groups) to
+	// be followed by a sibling string attribute, keyed by its key with a
+	// "_type" suffix, holding the slog.Kind of its value (such as "Int64").
+	// This lets sinks with typed schemas (such as ClickHouse columns) map the
+	// attributes to column types. LogValuer values are resolved first.
+	// Default is disabled.
+	TypedAttrs bool
+
+	// KnownKeys is the set of attribute keys that are expected to be found in
+	// the context. If set, OnUnknownKey is called for any other key, which helps
+	// catch typos such as "user_ID" instead of "user_id".
+	// Default is disabled.
+	KnownKeys map[string]bool
+
+	// OnUnknownKey is called with the key of every context attribute that is
+	// not in KnownKeys (such as to log a warning or increment a metric).
+	// It is called each time a record with the unknown key is handled.
+	OnUnknownKey func(key string)
 }
 
 var _ slog.Handler = &Handler{} // Assert conformance with interface
 
+// defaultCorrelationKeys are the keys of the attributes moved into HandlerOptions.CorrelationGroup by default.
+var defaultCorrelationKeys = []string{"trace_id", "span_id", "request_id", "tenant_id"}
+
 // NewMiddleware creates a yasctx.Handler slog.Handler middleware
 // that conforms to [github.com/samber/slog-multi.Middleware] interface.
 // It can be used with slogmulti methods such as Pipe to easily setup a pipeline of slog handlers:
@@ -45,28 +372,108 @@ func NewMiddleware() func(slog.Handler) slog.Handler {
 // Append attributes to log lines. The attributes are extracted out of the log
 // record's context by the provided AttrExtractor methods.
 // It passes the final record and attributes off to the next handler when finished.
-// If opts is nil, the default options are used.
 func NewHandler(next slog.Handler) *Handler {
+	return NewHandlerWithOptions(next, nil)
+}
 
-	prependers := []attrExtractor{
+// NewHandlerWithOptions creates a Handler slog.Handler middleware, like NewHandler, configured by opts.
+// If opts is nil, the default options are used.
+func NewHandlerWithOptions(next slog.Handler, opts *HandlerOptions) *Handler {
+	if opts == nil {
+		opts = &HandlerOptions{}
+	}
+	// Copy, so that defaults are not written to the caller's options
+	o := *opts
+	opts = &o
+	if opts.GroupSeparator == "" {
+		opts.GroupSeparator = "."
+	}
+
+	var prependers []AttrExtractor
+	if opts.SchemaVersion != "" {
+		prependers = append(prependers, StaticExtractor(slog.String("log_schema_version", opts.SchemaVersion)))
+	}
+	prependers = append(prependers,
 		extractPropagatedAttrs,
 		extractAdded,
+		extractFeatures,
+	)
+	if opts.RequestDuration {
+		prependers = append(prependers, extractRequestDuration)
+	}
+	if opts.TextOutput {
+		prependers = append(prependers, extractTextOnly)
 	}
+	switch opts.Sequence {
+	case SequenceHandler:
+		prependers = append(prependers, handlerSequenceExtractor())
+	case SequenceContext:
+		prependers = append(prependers, extractContextSequence)
+	}
+
+	perLevel := make([]levelPrependers, 0, len(opts.PerLevel))
+	for level, extractors := range opts.PerLevel {
+		perLevel = append(perLevel, levelPrependers{
+			level:      level,
+			prependers: append(slices.Clip(prependers), extractors...),
+		})
+	}
+	slices.SortFunc(perLevel, func(a, b levelPrependers) int { return int(a.level - b.level) })
 
 	return &Handler{
 		next:       next,
-		prependers: prependers,
+		prependers: append(slices.Clip(prependers), opts.Prependers...),
+		perLevel:   perLevel,
+		appenders:  slices.Clone(opts.Appenders),
+		transforms: buildValueTransformers(opts),
+		opts:       *opts,
 	}
 }
 
-// Enabled reports whether the next handler handles records at the given level.
+// Enabled reports whether the next handler (or the tenant's handler) handles records at the given level.
 // The handler ignores records whose level is lower.
+// Records using a context with attributes added by AddAudit are always
+// enabled when HandlerOptions.Audit is set, so that they are audited.
 func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
-	return h.next.Enabled(ctx, level)
+	if h.opts.Audit != nil && len(auditAttrs(ctx)) > 0 {
+		return true
+	}
+	return h.enabled(ctx, level)
+}
+
+// enabled reports whether the next handler handles records at the given level.
+func (h *Handler) enabled(ctx context.Context, level slog.Level) bool {
+	// Seeded decisions are consistent, so they can be made early, sparing the caller from building the record
+	if rate := h.opts.SampleRate; rate > 0 && rate < 1 {
+		if _, ok := ctx.Value(sampleSeedKey{}).(string); ok && !sampled(ctx, rate) {
+			return false
+		}
+	}
+	return h.nextFor(ctx).Enabled(ctx, levelFor(ctx, level))
 }
 
 // Handle de-duplicates all attributes and groups, then passes the new set of attributes to the next handler.
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var audited []slog.Attr
+	if h.opts.Audit != nil {
+		// Audit before sampling and the level check, which Enabled skipped
+		audited = h.audit(ctx, r)
+		if !h.enabled(ctx, r.Level) {
+			return nil
+		}
+	}
+	if isEnriched(ctx) && h.goa == nil {
+		// A Handler above has already added the context attributes
+		return h.nextFor(ctx).Handle(ctx, r)
+	}
+	if !sampled(ctx, h.opts.SampleRate) {
+		return nil
+	}
+	r.Level = levelFor(ctx, r.Level)
+	now := r.Time
+	if h.opts.Clock != nil {
+		now = h.opts.Clock.Now()
+	}
 
 	// Initialize a mapping from extractAddedToGroup() with added bool to track which groups were used.
 	// This will allow us to prepend any unused groups to the final attributes.
@@ -74,7 +481,7 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		attrs []slog.Attr
 		used  bool
 	}{}
-	for k, v := range extractAddedToGroup(ctx, r.Time, r.Level, r.Message) {
+	for k, v := range extractAddedToGroup(ctx, now, r.Level, r.Message) {
 		addedToGroup[k] = &struct {
 			attrs []slog.Attr
 			used  bool
@@ -100,7 +507,11 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 				if !ctxGroupAttrs.used {
 					// Mark this group as used, so we don't use it again.
 					ctxGroupAttrs.used = true
-					finalAttrs = append(slices.Clip(ctxGroupAttrs.attrs), finalAttrs...)
+					if h.groupAttrPosition(g.group) == PositionAfter {
+						finalAttrs = append(finalAttrs, h.processCtxAttrs(ctxGroupAttrs.attrs)...)
+					} else {
+						finalAttrs = append(h.processCtxAttrs(ctxGroupAttrs.attrs), finalAttrs...)
+					}
 				}
 			}
 			// If a group, put all the previous attributes (the newest ones) in it
@@ -114,17 +525,117 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		}
 	}
 
-	// Add in any unsued group attributes that were not used to the start (root)
-	for _, ctxGroupAttrs := range addedToGroup {
+	// Add in any unsued group attributes that were not used to the start (root),
+	// in order of the group names so that the output is deterministic.
+	unusedGroups := make([]string, 0, len(addedToGroup))
+	for group, ctxGroupAttrs := range addedToGroup {
 		if !ctxGroupAttrs.used {
-			finalAttrs = append(slices.Clip(ctxGroupAttrs.attrs), finalAttrs...)
+			unusedGroups = append(unusedGroups, group)
+		}
+	}
+	slices.Sort(unusedGroups)
+	var orphanedAttrs []slog.Attr
+	for _, group := range unusedGroups {
+		orphanedAttrs = append(orphanedAttrs, addedToGroup[group].attrs...)
+	}
+	if orphanedAttrs = h.processCtxAttrs(orphanedAttrs); len(orphanedAttrs) > 0 && h.opts.OrphanedGroup != "" {
+		orphanedAttrs = []slog.Attr{{Key: h.opts.OrphanedGroup, Value: slog.GroupValue(orphanedAttrs...)}}
+	}
+
+	// Add our 'prepended' context attributes to the start, in the order of the prependers,
+	// followed by the unused group attributes.
+	var ctxAttrs []slog.Attr
+	for _, prepender := range h.prependersFor(r.Level) {
+		ctxAttrs = append(ctxAttrs, prepender(ctx, now, r.Level, r.Message)...)
+	}
+	if r.PC == 0 {
+		for _, extractor := range h.opts.SourcelessExtractors {
+			ctxAttrs = append(ctxAttrs, extractor(ctx, now, r.Level, r.Message)...)
+		}
+	}
+	if len(h.opts.VerboseExtractors) > 0 && isVerbose(ctx) {
+		for _, extractor := range h.opts.VerboseExtractors {
+			ctxAttrs = append(ctxAttrs, extractor(ctx, now, r.Level, r.Message)...)
+		}
+	}
+	if len(h.opts.DiagnosticExtractors) > 0 && (r.Level >= slog.LevelError || hasCtxError(ctx)) {
+		for _, extractor := range h.opts.DiagnosticExtractors {
+			ctxAttrs = append(ctxAttrs, extractor(ctx, now, r.Level, r.Message)...)
+		}
+	}
+	ctxAttrs = append(h.processCtxAttrs(ctxAttrs), orphanedAttrs...)
+	if h.opts.Dedup && h.opts.DedupContextOnly {
+		ctxAttrs = dedupAttrs(ctxAttrs, h.opts.JoinDuplicates)
+	}
+
+	// Add our 'appended' context attributes to the end, in the order of the appenders,
+	// or between the prepended attributes and the record's attributes.
+	var appendedAttrs []slog.Attr
+	for i := range h.appenders {
+		appender := h.appenders[i]
+		if h.opts.ReverseAppenders {
+			appender = h.appenders[len(h.appenders)-1-i]
+		}
+		appendedAttrs = append(appendedAttrs, appender(ctx, now, r.Level, r.Message)...)
+	}
+	appendedAttrs = append(appendedAttrs, audited...)
+	appendedAttrs = h.processCtxAttrs(appendedAttrs)
+
+	ctxAttrs = transformCtxAttrs(ctx, ctxAttrs)
+	appendedAttrs = transformCtxAttrs(ctx, appendedAttrs)
+
+	// Check before the context attributes are moved into groups
+	if len(h.opts.RequireKeys) > 0 && h.opts.OnMissingRequired != nil {
+		h.checkRequiredKeys(ctxAttrs, finalAttrs, appendedAttrs)
+	}
+
+	// Move the correlation attributes of all extractors into their own group, at the start
+	if h.opts.CorrelationGroup != "" {
+		var correlation []slog.Attr
+		ctxAttrs, correlation = h.splitCorrelation(ctxAttrs, nil)
+		appendedAttrs, correlation = h.splitCorrelation(appendedAttrs, correlation)
+		if len(correlation) > 0 {
+			ctxAttrs = append([]slog.Attr{{Key: h.opts.CorrelationGroup, Value: slog.GroupValue(correlation...)}}, ctxAttrs...)
+		}
+	}
+
+	if h.opts.ContextGroupName != "" && r.Level >= h.contextGroupLevel() && len(ctxAttrs) > 0 {
+		ctxAttrs = []slog.Attr{{Key: h.opts.ContextGroupName, Value: slog.GroupValue(ctxAttrs...)}}
+	}
+
+	if h.opts.AppendersBeforeRecord {
+		finalAttrs = append(append(ctxAttrs, appendedAttrs...), finalAttrs...)
+	} else {
+		finalAttrs = append(append(ctxAttrs, finalAttrs...), appendedAttrs...)
+	}
+
+	finalAttrs = suppressRepeatedErrors(ctx, r, finalAttrs)
+
+	if h.opts.Dedup && !h.opts.DedupContextOnly {
+		finalAttrs = dedupAttrs(finalAttrs, h.opts.JoinDuplicates)
+		if h.opts.DedupAcrossGroups {
+			finalAttrs = dedupAcrossGroups(finalAttrs)
 		}
 	}
 
-	// Add our 'prepended' context attributes to the start.
-	// Go in reverse order, since each is prepending to the front.
-	for i := len(h.prependers) - 1; i >= 0; i-- {
-		finalAttrs = append(slices.Clip(h.prependers[i](ctx, r.Time, r.Level, r.Message)), finalAttrs...)
+	if h.opts.CollapseIdenticalGroups {
+		finalAttrs = collapseIdenticalGroups(finalAttrs)
+	}
+
+	if h.opts.GroupPrefix != "" {
+		finalAttrs = prefixGroups(finalAttrs, h.opts.GroupPrefix, h.opts.GroupPrefixNested)
+	}
+
+	if h.opts.StripInternalMarkers {
+		finalAttrs = stripInternalMarkers(finalAttrs)
+	}
+
+	if h.opts.FlattenGroups {
+		finalAttrs = flattenAttrs(nil, "", h.opts.GroupSeparator, finalAttrs)
+	}
+
+	if h.opts.Metrics != nil {
+		h.opts.Metrics.ObserveAttrsPerRecord(countAttrs(finalAttrs))
 	}
 
 	// Add all attributes to new record (because old record has all the old attributes as private members)
@@ -134,10 +645,323 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		Message: r.Message,
 		PC:      r.PC,
 	}
+	if h.opts.MessageTransform != nil {
+		newR.Message = h.opts.MessageTransform(ctx, r.Message)
+	}
 
 	// Add attributes back in
 	newR.AddAttrs(finalAttrs...)
-	return h.next.Handle(ctx, *newR)
+	if h.opts.CrashBuffer != nil {
+		h.opts.CrashBuffer.add(newR.Clone())
+	}
+	if h.opts.ShareEnrichment {
+		ctx = context.WithValue(ctx, enrichedKey{}, true)
+	}
+	if h.opts.Tee != nil && h.opts.Tee.Enabled(ctx, newR.Level) {
+		// The tee must not affect the primary path, so its errors are ignored
+		_ = h.opts.Tee.Handle(ctx, newR.Clone())
+	}
+	return h.nextFor(ctx).Handle(ctx, *newR)
+}
+
+// audit calls HandlerOptions.Audit, unless the record was already audited by
+// another Handler, and returns the audit attributes of the log line.
+func (h *Handler) audit(ctx context.Context, r slog.Record) []slog.Attr {
+	entry, ok := ctx.Value(auditKey{}).(*auditEntry)
+	if !ok || len(entry.attrs) == 0 {
+		return nil
+	}
+	if !entry.claim(r) {
+		return entry.attrs
+	}
+	now := r.Time
+	if h.opts.Clock != nil {
+		now = h.opts.Clock.Now()
+	}
+	return h.opts.Audit(ctx, now, r.Level, r.Message)
+}
+
+// enrichedKey marks a context whose records have had their context attributes
+// added by a Handler with HandlerOptions.ShareEnrichment.
+type enrichedKey struct{}
+
+// isEnriched reports whether the context was marked by a Handler with HandlerOptions.ShareEnrichment.
+func isEnriched(ctx context.Context) bool {
+	enriched, _ := ctx.Value(enrichedKey{}).(bool)
+	return enriched
+}
+
+// ExtractorNames returns the names of all prependers and then all appenders,
+// in the order they were registered, for diagnostics and to verify the
+// configuration at startup. The name of an extractor is the name of its
+// function, qualified by its package name (such as "yasctx.extractAdded").
+// The extractors of HandlerOptions.PerLevel, HandlerOptions.SourcelessExtractors,
+// HandlerOptions.VerboseExtractors, and HandlerOptions.DiagnosticExtractors
+// are not included.
+// Extractors created by function literals are named after the enclosing
+// function (such as "yasctx.DerivedExtractor.func1").
+func (h *Handler) ExtractorNames() []string {
+	names := make([]string, 0, len(h.prependers)+len(h.appenders))
+	for _, extractor := range append(slices.Clip(h.prependers), h.appenders...) {
+		name := runtime.FuncForPC(reflect.ValueOf(extractor).Pointer()).Name()
+		names = append(names, name[strings.LastIndex(name, "/")+1:])
+	}
+	return names
+}
+
+// splitCorrelation returns the attributes without the correlation attributes,
+// and the correlation attributes appended to correlation.
+func (h *Handler) splitCorrelation(attrs []slog.Attr, correlation []slog.Attr) (rest []slog.Attr, _ []slog.Attr) {
+	keys := h.opts.CorrelationKeys
+	if keys == nil {
+		keys = defaultCorrelationKeys
+	}
+	// Build a new slice, because the attributes may be those stored in the context
+	rest = make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if slices.Contains(keys, a.Key) {
+			correlation = append(correlation, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return rest, correlation
+}
+
+// checkRequiredKeys calls OnMissingRequired if any of RequireKeys is missing
+// from the root level of all of the sets of attributes.
+func (h *Handler) checkRequiredKeys(attrSets ...[]slog.Attr) {
+	var missing []string
+	for _, key := range h.opts.RequireKeys {
+		found := false
+		for _, attrs := range attrSets {
+			if slices.ContainsFunc(attrs, func(a slog.Attr) bool { return a.Key == key }) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		h.opts.OnMissingRequired(missing)
+	}
+}
+
+// nextFor returns the handler the records using the context are passed to.
+func (h *Handler) nextFor(ctx context.Context) slog.Handler {
+	if h.opts.TenantHandlers != nil {
+		if tenantID, ok := tenantFromCtx(ctx); ok {
+			if next, ok := h.opts.TenantHandlers[tenantID]; ok {
+				return next
+			}
+		}
+	}
+	return h.next
+}
+
+// prependersFor returns the prependers used for records of the level.
+func (h *Handler) prependersFor(level slog.Level) []AttrExtractor {
+	for i := len(h.perLevel) - 1; i >= 0; i-- {
+		if h.perLevel[i].level <= level {
+			return h.perLevel[i].prependers
+		}
+	}
+	return h.prependers
+}
+
+// contextGroupLevel returns the minimum level of the log lines whose context
+// attributes are nested in ContextGroupName.
+func (h *Handler) contextGroupLevel() slog.Level {
+	if h.opts.ContextGroupLevel == nil {
+		return slog.LevelWarn
+	}
+	return h.opts.ContextGroupLevel.Level()
+}
+
+// groupAttrPosition returns the position of the attributes added to the group.
+func (h *Handler) groupAttrPosition(group string) Position {
+	if pos, ok := h.opts.GroupAttrPositions[group]; ok {
+		return pos
+	}
+	return h.opts.GroupAttrPosition
+}
+
+// processCtxAttrs applies the handler options to attributes that came from the context.
+// The returned slice is always safe to append to.
+func (h *Handler) processCtxAttrs(attrs []slog.Attr) []slog.Attr {
+	// The attributes extracted from the context are shared with other log lines
+	// (and may be stored in the context), so they must not be modified in place.
+	// owned tracks whether attrs is a copy made by a step below, so that it is
+	// copied at most once.
+	owned := false
+	own := func() {
+		if !owned {
+			attrs = slices.Clone(attrs)
+			owned = true
+		}
+	}
+
+	if !h.opts.KeepEmptyAttrs && slices.ContainsFunc(attrs, isEmptyAttr) {
+		own()
+		attrs = slices.DeleteFunc(attrs, isEmptyAttr)
+	}
+	if len(h.opts.KeySampleRates) > 0 {
+		own()
+		attrs = slices.DeleteFunc(attrs, func(a slog.Attr) bool {
+			rate, ok := h.opts.KeySampleRates[a.Key]
+			return ok && !keySampled(rate)
+		})
+	}
+	if h.opts.KnownKeys != nil && h.opts.OnUnknownKey != nil {
+		for _, a := range attrs {
+			if !h.opts.KnownKeys[a.Key] {
+				h.opts.OnUnknownKey(a.Key)
+			}
+		}
+	}
+
+	if h.transforms != nil || h.opts.KeyRemap != nil || h.opts.BytesEncoding != BytesUnchanged {
+		own()
+		for i := range attrs {
+			if key, ok := h.opts.KeyRemap[attrs[i].Key]; ok {
+				attrs[i].Key = key
+			}
+			if transform, ok := h.transforms[attrs[i].Key]; ok {
+				attrs[i].Value = transform(attrs[i].Value)
+			}
+			if h.opts.BytesEncoding != BytesUnchanged {
+				attrs[i].Value = encodeBytes(attrs[i].Value, h.opts.BytesEncoding)
+			}
+		}
+	}
+
+	if h.opts.MaxSliceLen > 0 || len(h.opts.MaxSliceLens) > 0 {
+		attrs, owned = capSlices(attrs, h.opts.MaxSliceLen, h.opts.MaxSliceLens), true
+	}
+
+	if h.opts.Dedup && h.opts.DedupContextOnly {
+		attrs, owned = dedupAttrs(attrs, h.opts.JoinDuplicates), true
+	}
+
+	if h.opts.AttrLess != nil {
+		own()
+		slices.SortStableFunc(attrs, func(a, b slog.Attr) int {
+			if h.opts.AttrLess(a, b) {
+				return -1
+			}
+			if h.opts.AttrLess(b, a) {
+				return 1
+			}
+			return 0
+		})
+	}
+
+	if h.opts.TypedAttrs {
+		attrs = typedAttrs(attrs)
+	}
+	return slices.Clip(attrs)
+}
+
+// typedAttrs returns a new slice of the attributes, each followed by a
+// "_type" sibling holding the kind of its value, recursing into groups.
+func typedAttrs(attrs []slog.Attr) []slog.Attr {
+	typed := make([]slog.Attr, 0, 2*len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(typedAttrs(a.Value.Group())...)
+		}
+		typed = append(typed, a, slog.String(a.Key+"_type", a.Value.Kind().String()))
+	}
+	return typed
+}
+
+// stripInternalMarkers returns the attributes without those whose keys start
+// with an underscore, recursing into groups.
+func stripInternalMarkers(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+			a.Value = slog.GroupValue(stripInternalMarkers(v.Group())...)
+		} else if strings.HasPrefix(a.Key, "_") {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// isEmptyAttr reports whether the attribute is the zero slog.Attr, with an empty key and value.
+func isEmptyAttr(a slog.Attr) bool {
+	return a.Equal(slog.Attr{})
+}
+
+// collapseIdenticalGroups returns the attributes with each group whose
+// contents are identical to an earlier group at the same level replaced by a
+// reference to it, recursing into groups.
+func collapseIdenticalGroups(attrs []slog.Attr) []slog.Attr {
+	// Copy, because the attributes may be shared with the context or WithAttrs
+	attrs = slices.Clone(attrs)
+	for i, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() != slog.KindGroup || a.Key == "" || len(a.Value.Group()) == 0 {
+			continue
+		}
+		a.Value = slog.GroupValue(collapseIdenticalGroups(a.Value.Group())...)
+		attrs[i] = a
+		for _, earlier := range attrs[:i] {
+			if earlier.Key != "" && earlier.Value.Kind() == slog.KindGroup && earlier.Value.Equal(a.Value) {
+				attrs[i] = slog.String(a.Key, "@"+earlier.Key)
+				break
+			}
+		}
+	}
+	return attrs
+}
+
+// prefixGroups returns the attributes with the prefix added to the names of
+// groups, recursing into nested groups if nested is set.
+func prefixGroups(attrs []slog.Attr, prefix string, nested bool) []slog.Attr {
+	// Copy, because the attributes may be shared with the context or WithAttrs
+	attrs = slices.Clone(attrs)
+	for i, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() != slog.KindGroup {
+			continue
+		}
+		// Groups with an empty key are inlined, so their attributes are at this level
+		inlined := a.Key == ""
+		if !inlined {
+			a.Key = prefix + a.Key
+		}
+		if inlined || nested {
+			a.Value = slog.GroupValue(prefixGroups(a.Value.Group(), prefix, nested)...)
+		}
+		attrs[i] = a
+	}
+	return attrs
+}
+
+// flattenAttrs appends the attributes to dst, replacing any groups with their
+// attributes whose keys are prefixed by the group name and separator.
+func flattenAttrs(dst []slog.Attr, prefix string, sep string, attrs []slog.Attr) []slog.Attr {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() != slog.KindGroup {
+			a.Key = prefix + a.Key
+			dst = append(dst, a)
+			continue
+		}
+		// Groups with an empty key are inlined
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + sep
+		}
+		dst = flattenAttrs(dst, groupPrefix, sep, a.Value.Group())
+	}
+	return dst
 }
 
 // WithGroup returns a new AppendHandler that still has h's attributes,
@@ -1,11 +1,42 @@
 package yasctx
 
 import (
+	"context"
 	"log/slog"
+	"runtime"
+	"strings"
+	"time"
 )
 
+// logAttrs logs like slog.Logger.LogAttrs, but with the source of the first
+// caller outside of the runtime package, after skipping skip frames (as for
+// runtime.Callers), so that the helpers of this package are not reported as
+// the source of their log lines.
+func logAttrs(ctx context.Context, logger *slog.Logger, level slog.Level, msg string, skip int, attrs ...slog.Attr) {
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	var pc uintptr
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	for i := 0; i < n; i++ {
+		// Skip the frames of the runtime package, such as those of a panic
+		if frame, _ := runtime.CallersFrames(pcs[i : i+1]).Next(); !strings.HasPrefix(frame.Function, "runtime.") {
+			pc = pcs[i]
+			break
+		}
+	}
+
+	r := slog.NewRecord(time.Now(), level, msg, pc)
+	r.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
 // groupOrAttrs holds either a group name or a list of slog.Attrs.
 // It also holds a reference/link to its parent groupOrAttrs, forming a linked list.
+// A linked list is used rather than a slice, so that WithGroup and WithAttrs
+// never copy the existing chain (see BenchmarkGoa).
 // Courtesy of https://github.com/jba/slog/blob/b5eef75b08965b871bd5214891313b73d5a30432/withsupport/withsupport.go
 type groupOrAttrs struct {
 	group string        // group name if non-empty
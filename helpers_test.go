@@ -0,0 +1,189 @@
+package yasctx
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// goaSlice is a slice-based alternative to the groupOrAttrs linked list,
+// ordered from oldest to newest. It is kept here to benchmark against the
+// linked list, which is what the Handler uses.
+type goaSlice []groupOrAttrs
+
+func (s goaSlice) WithGroup(name string) goaSlice {
+	if name == "" {
+		return s
+	}
+	// Clip to ensure this is a scoped copy
+	return append(slices.Clip(s), groupOrAttrs{group: name})
+}
+
+func (s goaSlice) WithAttrs(attrs []slog.Attr) goaSlice {
+	if len(attrs) == 0 {
+		return s
+	}
+	return append(slices.Clip(s), groupOrAttrs{attrs: attrs})
+}
+
+// apply nests the record attributes into the groups, newest to oldest.
+func (s goaSlice) apply(finalAttrs []slog.Attr) []slog.Attr {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i].group != "" {
+			finalAttrs = []slog.Attr{{Key: s[i].group, Value: slog.GroupValue(finalAttrs...)}}
+		} else {
+			finalAttrs = append(slices.Clip(s[i].attrs), finalAttrs...)
+		}
+	}
+	return finalAttrs
+}
+
+// applyList nests the record attributes into the groups, the same way Handle walks the linked list.
+func applyList(g *groupOrAttrs, finalAttrs []slog.Attr) []slog.Attr {
+	for ; g != nil; g = g.next {
+		if g.group != "" {
+			finalAttrs = []slog.Attr{{Key: g.group, Value: slog.GroupValue(finalAttrs...)}}
+		} else {
+			finalAttrs = append(slices.Clip(g.attrs), finalAttrs...)
+		}
+	}
+	return finalAttrs
+}
+
+// goaOp is a WithGroup (if attrs is nil) or WithAttrs operation.
+type goaOp struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (op goaOp) String() string {
+	if op.attrs == nil {
+		return fmt.Sprintf("WithGroup(%q)", op.group)
+	}
+	return fmt.Sprintf("WithAttrs(%v)", op.attrs)
+}
+
+// goaOpMatrix returns every sequence of up to depth operations.
+func goaOpMatrix(depth int) [][]goaOp {
+	ops := []goaOp{
+		{group: "g1"},
+		{group: ""},
+		{attrs: []slog.Attr{slog.String("k1", "v1")}},
+		{attrs: []slog.Attr{}},
+		{attrs: []slog.Attr{slog.Int("k2", 2), slog.Group("inner", "k3", "v3")}},
+	}
+	seqs := [][]goaOp{nil}
+	all := [][]goaOp{nil}
+	for i := 0; i < depth; i++ {
+		var next [][]goaOp
+		for _, seq := range seqs {
+			for _, op := range ops {
+				next = append(next, append(slices.Clip(seq), op))
+			}
+		}
+		all = append(all, next...)
+		seqs = next
+	}
+	return all
+}
+
+func TestGoaMatrix(t *testing.T) {
+	t.Parallel()
+
+	for _, seq := range goaOpMatrix(3) {
+		var list *groupOrAttrs
+		var slice goaSlice
+
+		var expected, got bytes.Buffer
+		native := slog.New(slog.NewJSONHandler(&expected, nil))
+		wrapped := slog.New(NewHandler(slog.NewJSONHandler(&got, nil)))
+
+		for _, op := range seq {
+			if op.attrs == nil {
+				list, slice = list.WithGroup(op.group), slice.WithGroup(op.group)
+				native, wrapped = native.WithGroup(op.group), wrapped.WithGroup(op.group)
+			} else {
+				list, slice = list.WithAttrs(op.attrs), slice.WithAttrs(op.attrs)
+				native, wrapped = native.With(attrsToArgs(op.attrs)...), wrapped.With(attrsToArgs(op.attrs)...)
+			}
+		}
+
+		record := []slog.Attr{slog.String("main1", "arg1")}
+		if l, s := fmt.Sprint(applyList(list, slices.Clone(record))), fmt.Sprint(slice.apply(slices.Clone(record))); l != s {
+			t.Errorf("%v: linked list and slice differ:\n%s\n%s", seq, l, s)
+		}
+
+		native.Info("main message", "main1", "arg1")
+		wrapped.Info("main message", "main1", "arg1")
+		if stripTime(expected.String()) != stripTime(got.String()) {
+			t.Errorf("%v: expected:\n%s\nGot:\n%s", seq, expected.String(), got.String())
+		}
+	}
+}
+
+func attrsToArgs(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return args
+}
+
+// stripTime removes the time field from a JSON log line.
+func stripTime(s string) string {
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		return s[i:]
+	}
+	return s
+}
+
+func BenchmarkGoa(b *testing.B) {
+	for _, depth := range []int{3, 30} {
+		ops := make([]goaOp, depth)
+		for i := range ops {
+			if i%2 == 0 {
+				ops[i] = goaOp{attrs: []slog.Attr{slog.Int("k", i)}}
+			} else {
+				ops[i] = goaOp{group: fmt.Sprintf("g%d", i)}
+			}
+		}
+		record := []slog.Attr{slog.String("main1", "arg1")}
+
+		b.Run(fmt.Sprintf("list/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var list *groupOrAttrs
+				for _, op := range ops {
+					if op.attrs == nil {
+						list = list.WithGroup(op.group)
+					} else {
+						list = list.WithAttrs(op.attrs)
+					}
+				}
+				for j := 0; j < 10; j++ {
+					applyList(list, slices.Clone(record))
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("slice/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var slice goaSlice
+				for _, op := range ops {
+					if op.attrs == nil {
+						slice = slice.WithGroup(op.group)
+					} else {
+						slice = slice.WithAttrs(op.attrs)
+					}
+				}
+				for j := 0; j < 10; j++ {
+					slice.apply(slices.Clone(record))
+				}
+			}
+		})
+	}
+}
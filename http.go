@@ -0,0 +1,92 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type headersKey struct{}
+
+// WithRequestHeaders stores the headers of a request in the returned context,
+// so that extractors such as ExtractTraceParent can read them.
+func WithRequestHeaders(parent context.Context, h http.Header) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, headersKey{}, h)
+}
+
+// DebugHeader is the request header read by VerboseMiddleware.
+const DebugHeader = "X-Debug"
+
+// VerboseMiddleware is an HTTP middleware that turns on verbose logging (see
+// WithVerbose) for requests whose X-Debug header is true (such as "1" or "true").
+func VerboseMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verbose, err := strconv.ParseBool(r.Header.Get(DebugHeader)); err == nil && verbose {
+			r = r.WithContext(WithVerbose(r.Context(), true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ExtractTraceParent is an AttrExtractor that parses the W3C "traceparent"
+// header stored by WithRequestHeaders, and adds "trace_id" and "span_id"
+// attributes. This lets log lines be correlated with traces without running
+// the OpenTelemetry SDK.
+// Add it to the HandlerOptions Prependers or Appenders to use it.
+// If the header is missing or malformed, no attributes are added.
+func ExtractTraceParent(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	h, ok := ctx.Value(headersKey{}).(http.Header)
+	if !ok {
+		return nil
+	}
+	traceID, spanID, ok := parseTraceParent(h.Get("traceparent"))
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{slog.String("trace_id", traceID), slog.String("span_id", spanID)}
+}
+
+// parseTraceParent returns the trace id and parent span id of a W3C traceparent
+// header value, in the form "version-traceid-parentid-flags".
+func parseTraceParent(s string) (traceID string, spanID string, ok bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// Version ff is invalid, and version 00 has exactly four parts.
+	// Future versions may append more parts, which we ignore.
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// isLowerHex reports whether s is n lowercase hexadecimal characters.
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
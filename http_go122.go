@@ -0,0 +1,59 @@
+//go:build go1.22
+
+package yasctx
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// PathValuesMiddleware is an HTTP middleware that adds the path parameters of
+// a net/http ServeMux route pattern (such as "GET /users/{id}") to the request
+// context, as a "path_params" group (such as "path_params.id"). The group is
+// not named "path", which is used by PushPath.
+// The pattern should be the one the handler is registered with:
+//
+//	mux.Handle(pattern, yasctx.PathValuesMiddleware(pattern, handler))
+//
+// Parameters that are empty for a request are omitted.
+// Path parameters need the ServeMux patterns of Go 1.22, which are disabled
+// for main modules that declare an older go version (see the httpmuxgo121
+// GODEBUG setting).
+func PathValuesMiddleware(pattern string, next http.Handler) http.Handler {
+	names := pathValueNames(pattern)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args := make([]any, 0, 2*len(names))
+		for _, name := range names {
+			if v := r.PathValue(name); v != "" {
+				args = append(args, name, v)
+			}
+		}
+		if len(args) > 0 {
+			r = r.WithContext(Add(r.Context(), slog.Group("path_params", args...)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pathValueNames returns the names of the wildcards of a ServeMux pattern,
+// such as "id" and "rest" for "/users/{id}/{rest...}".
+// The "{$}" end marker is not a wildcard.
+func pathValueNames(pattern string) []string {
+	var names []string
+	for {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(pattern[start:], '}')
+		if end < 0 {
+			return names
+		}
+		name := strings.TrimSuffix(pattern[start+1:start+end], "...")
+		if name != "" && name != "$" {
+			names = append(names, name)
+		}
+		pattern = pattern[start+end+1:]
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.22
+
+// The module declares go 1.21, which keeps the Go 1.21 ServeMux patterns by default
+//go:debug httpmuxgo121=0
+
+package yasctx_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestPathValuesMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+	logRequest := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.InfoContext(r.Context(), "request")
+	})
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{
+		"GET /users/{id}",
+		"GET /orgs/{org}/repos/{repo}/{file...}",
+		"GET /{$}",
+	} {
+		mux.Handle(pattern, yasctx.PathValuesMiddleware(pattern, logRequest))
+	}
+	for _, path := range []string{"/users/42", "/orgs/acme/repos/api/cmd/main.go", "/"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=request path_params.id=42
+time=2023-09-29T13:00:59.000Z level=INFO msg=request path_params.org=acme path_params.repo=api path_params.file=cmd/main.go
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
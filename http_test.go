@@ -0,0 +1,99 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestExtractTraceParent(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Prependers: []yasctx.AttrExtractor{yasctx.ExtractTraceParent},
+	}))
+
+	for _, traceparent := range []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",       // valid
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", // valid future version
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", // version 00 with extra part
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",       // invalid version
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",       // uppercase
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",       // zero trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",       // zero span id
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",        // short trace id
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",        // short flags
+	} {
+		h := http.Header{}
+		h.Set("traceparent", traceparent)
+		l.InfoContext(yasctx.WithRequestHeaders(context.Background(), h), "request")
+	}
+	l.InfoContext(context.Background(), "no headers")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=request trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7
+time=2023-09-29T13:00:59.000Z level=INFO msg=request trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg=request
+time=2023-09-29T13:00:59.000Z level=INFO msg="no headers"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestVerboseMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		VerboseExtractors: []yasctx.AttrExtractor{
+			func(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+				return []slog.Attr{slog.String("remote_addr", "192.0.2.1:1234"), slog.Int("pool_size", 8)}
+			},
+		},
+	}))
+
+	handler := yasctx.VerboseMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.InfoContext(yasctx.Add(r.Context(), "debug", r.Header.Get(yasctx.DebugHeader)), "request")
+	}))
+	for _, debug := range []string{"1", "true", "", "0", "garbage"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if debug != "" {
+			r.Header.Set(yasctx.DebugHeader, debug)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// Verbose can also be turned on and off directly
+	verbose := yasctx.WithVerbose(context.Background(), true)
+	l.InfoContext(verbose, "verbose")
+	l.InfoContext(yasctx.WithVerbose(verbose, false), "not verbose")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=request debug=1 remote_addr=192.0.2.1:1234 pool_size=8
+time=2023-09-29T13:00:59.000Z level=INFO msg=request debug=true remote_addr=192.0.2.1:1234 pool_size=8
+time=2023-09-29T13:00:59.000Z level=INFO msg=request debug=""
+time=2023-09-29T13:00:59.000Z level=INFO msg=request debug=0
+time=2023-09-29T13:00:59.000Z level=INFO msg=request debug=garbage
+time=2023-09-29T13:00:59.000Z level=INFO msg=verbose remote_addr=192.0.2.1:1234 pool_size=8
+time=2023-09-29T13:00:59.000Z level=INFO msg="not verbose"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,55 @@
+package yasctx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestWithInternedKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, interned := range []bool{false, true} {
+		base := context.Background()
+		if interned {
+			base = WithInternedKeys(base)
+		}
+
+		// Build the keys at runtime, so they don't share memory to begin with
+		ctx1 := Add(base, strings.Repeat("k", 3), "arg1")
+		ctx2 := AddToGroup(base, "group1", strings.Repeat("k", 3), "arg2")
+
+		key1 := extractAdded(ctx1, time.Time{}, 0, "")[0].Key
+		key2 := extractAddedToGroup(ctx2, time.Time{}, 0, "")["group1"][0].Key
+		if key1 != key2 {
+			t.Errorf("Expected equal keys; Got %q and %q", key1, key2)
+		}
+		if shared := unsafe.StringData(key1) == unsafe.StringData(key2); shared != interned {
+			t.Errorf("Interned %v expected shared memory %v; Got %v", interned, interned, shared)
+		}
+	}
+}
+
+func BenchmarkWithInternedKeys(b *testing.B) {
+	for _, interned := range []bool{false, true} {
+		b.Run(fmt.Sprintf("intern=%t", interned), func(b *testing.B) {
+			l := slog.New(NewHandler(slog.NewJSONHandler(io.Discard, nil)))
+			base := context.Background()
+			if interned {
+				base = WithInternedKeys(base)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Build the keys at runtime, as if they had been decoded from a request
+				ctx := Add(base, string([]byte("request_id")), i, string([]byte("user_id")), i)
+				l.InfoContext(ctx, "main message", "main1", "arg1")
+			}
+		})
+	}
+}
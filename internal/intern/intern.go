@@ -0,0 +1,36 @@
+// Package intern provides a bounded table for interning strings, such as attribute keys.
+package intern
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Table interns strings, so that equal strings share the same backing memory.
+// Once the table holds max strings, new strings are returned as-is, which
+// bounds the memory used when the set of strings is unexpectedly large.
+type Table struct {
+	m   sync.Map
+	n   atomic.Int64
+	max int64
+}
+
+// NewTable creates a Table that holds at most max strings.
+func NewTable(max int) *Table {
+	return &Table{max: int64(max)}
+}
+
+// Intern returns the canonical copy of s.
+func (t *Table) Intern(s string) string {
+	if v, ok := t.m.Load(s); ok {
+		return v.(string)
+	}
+	if t.n.Load() >= t.max {
+		return s
+	}
+	v, loaded := t.m.LoadOrStore(s, s)
+	if !loaded {
+		t.n.Add(1)
+	}
+	return v.(string)
+}
@@ -0,0 +1,31 @@
+package intern
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestIntern(t *testing.T) {
+	table := NewTable(2)
+
+	// Build the strings at runtime, so they don't share memory to begin with
+	a1 := strings.Repeat("a", 3)
+	a2 := strings.Repeat("a", 3)
+	if unsafe.StringData(a1) == unsafe.StringData(a2) {
+		t.Fatal("Expected distinct strings")
+	}
+
+	i1, i2 := table.Intern(a1), table.Intern(a2)
+	if i1 != i2 || unsafe.StringData(i1) != unsafe.StringData(i2) {
+		t.Error("Expected interned strings to share memory")
+	}
+
+	// Fill the table, then check new strings are returned as-is
+	table.Intern(strings.Repeat("b", 3))
+	c1 := strings.Repeat("c", 3)
+	c2 := strings.Repeat("c", 3)
+	if i1, i2 := table.Intern(c1), table.Intern(c2); i1 != i2 || unsafe.StringData(i1) == unsafe.StringData(i2) {
+		t.Error("Expected strings beyond the max to be returned as-is")
+	}
+}
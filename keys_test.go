@@ -0,0 +1,104 @@
+package yasctx
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestHandlerKnownKeys(t *testing.T) {
+	t.Parallel()
+
+	var unknown []string
+	tester := &test.Handler{}
+	h := NewHandlerWithOptions(tester, &HandlerOptions{
+		KnownKeys:    map[string]bool{"user_id": true, "request_id": true},
+		OnUnknownKey: func(key string) { unknown = append(unknown, key) },
+	})
+
+	ctx := Add(nil, "request_id", "abc", "user_ID", 24680)
+	ctx = AddToGroup(ctx, "group1", "user_id", 24680)
+
+	l := slog.New(h)
+	l.InfoContext(ctx, "main message", "not_from_ctx", "arg1")
+
+	if len(unknown) != 1 || unknown[0] != "user_ID" {
+		t.Errorf("Expected only user_ID to be unknown; Got: %v", unknown)
+	}
+
+	expectedText := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=abc user_ID=24680 user_id=24680 not_from_ctx=arg1
+`
+	if s := tester.String(); s != expectedText {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expectedText, s)
+	}
+}
+
+func TestHandlerRequireKeys(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var missing [][]string
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		RequireKeys: []string{"request_id", "user_id"},
+		OnMissingRequired: func(keys []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			missing = append(missing, keys)
+		},
+	}))
+
+	ctx := Add(nil, "request_id", "abc")
+	l.InfoContext(ctx, "all present", "user_id", 24680)
+	l.InfoContext(ctx, "one missing")
+	l.WithGroup("group1").Info("all missing", "request_id", "nested")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="all present" request_id=abc user_id=24680
+time=2023-09-29T13:00:59.000Z level=INFO msg="one missing" request_id=abc
+time=2023-09-29T13:00:59.000Z level=INFO msg="all missing" group1.request_id=nested
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	if s := fmt.Sprint(missing); s != "[[user_id] [request_id user_id]]" {
+		t.Errorf("Unexpected missing keys: %s", s)
+	}
+}
+
+func TestHandlerRequireKeysGrouped(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var missing [][]string
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		RequireKeys:       []string{"request_id", "user_id"},
+		CorrelationGroup:  "correlation",
+		ContextGroupName:  "ctx",
+		ContextGroupLevel: slog.LevelInfo,
+		OnMissingRequired: func(keys []string) {
+			mu.Lock()
+			defer mu.Unlock()
+			missing = append(missing, keys)
+		},
+	}))
+
+	ctx := Add(nil, "request_id", "abc", "user_id", 24680)
+	l.InfoContext(ctx, "all present")
+	l.InfoContext(Add(nil, "user_id", 13579), "one missing")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="all present" ctx.correlation.request_id=abc ctx.user_id=24680
+time=2023-09-29T13:00:59.000Z level=INFO msg="one missing" ctx.user_id=13579
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	if s := fmt.Sprint(missing); s != "[[request_id]]" {
+		t.Errorf("Unexpected missing keys: %s", s)
+	}
+}
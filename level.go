@@ -0,0 +1,28 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type levelOverrideKey struct{}
+
+// WithLevelOverride forces the log lines using the returned context to be
+// written at the level, whatever level they were logged at. This can demote
+// noisy logs (such as those of a third-party library) to Debug for a flagged
+// flow, or promote them so that they are not filtered out.
+// The Handler decides whether a log line is enabled by the overridden level.
+func WithLevelOverride(parent context.Context, level slog.Level) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, levelOverrideKey{}, level)
+}
+
+// levelFor returns the level overridden by WithLevelOverride, or the level.
+func levelFor(ctx context.Context, level slog.Level) slog.Level {
+	if override, ok := ctx.Value(levelOverrideKey{}).(slog.Level); ok {
+		return override
+	}
+	return level
+}
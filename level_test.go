@@ -0,0 +1,46 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestWithLevelOverride(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	h := yasctx.NewHandler(tester)
+	l := slog.New(h)
+
+	demoted := yasctx.WithLevelOverride(context.Background(), slog.LevelDebug)
+	promoted := yasctx.WithLevelOverride(context.Background(), slog.LevelWarn)
+	// The test handler is only enabled for Debug and above
+	hidden := yasctx.WithLevelOverride(context.Background(), slog.LevelDebug-4)
+
+	l.InfoContext(context.Background(), "normal")
+	l.ErrorContext(demoted, "demoted")
+	l.DebugContext(promoted, "promoted")
+	l.InfoContext(yasctx.Add(promoted, "child", true), "promoted child")
+	l.ErrorContext(hidden, "hidden")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=normal
+time=2023-09-29T13:00:59.000Z level=DEBUG msg=demoted
+time=2023-09-29T13:00:59.000Z level=WARN msg=promoted
+time=2023-09-29T13:00:59.000Z level=WARN msg="promoted child" child=true
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Enabled uses the overridden level
+	if h.Enabled(hidden, slog.LevelError) {
+		t.Error("Expected a hidden error to be disabled")
+	}
+	if !h.Enabled(promoted, slog.LevelDebug-4) {
+		t.Error("Expected a promoted line to be enabled")
+	}
+}
@@ -0,0 +1,28 @@
+package yasctx
+
+import "log/slog"
+
+// Metrics receives observations about the log lines handled by a Handler,
+// to be recorded by a metrics system (such as a Prometheus histogram).
+// Set it as HandlerOptions.Metrics. Its methods must be safe for concurrent use.
+type Metrics interface {
+	// ObserveAttrsPerRecord is called for each log line, with its number of
+	// attributes after those from the context are added. Attributes within
+	// groups are counted, but not the groups themselves.
+	// It lets operators monitor the distribution of enrichment, and catch
+	// contexts accumulating attributes without bound.
+	ObserveAttrsPerRecord(n int)
+}
+
+// countAttrs returns the number of attributes, counting those within groups instead of the groups.
+func countAttrs(attrs []slog.Attr) int {
+	n := 0
+	for _, a := range attrs {
+		if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+			n += countAttrs(v.Group())
+		} else {
+			n++
+		}
+	}
+	return n
+}
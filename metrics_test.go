@@ -0,0 +1,50 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+// fakeMetrics is a yasctx.Metrics that records the observations.
+type fakeMetrics struct {
+	mu            sync.Mutex
+	attrsPerLines []int
+}
+
+func (m *fakeMetrics) ObserveAttrsPerRecord(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attrsPerLines = append(m.attrsPerLines, n)
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := &fakeMetrics{}
+	l := slog.New(yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{
+		Metrics:   metrics,
+		Appenders: []yasctx.AttrExtractor{yasctx.StaticExtractor("service", "api")},
+	}))
+
+	l.Info("appender only")
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc", slog.Group("user", "id", 1, "role", "admin"))
+	ctx = yasctx.AddToGroup(ctx, "group1", "ctx2", "arg1")
+	l.WithGroup("group1").With("with1", "arg1").InfoContext(ctx, "merged", "main1", "arg1")
+
+	// A context accumulating attributes
+	for i := 0; i < 10; i++ {
+		ctx = yasctx.Add(ctx, "step", i)
+	}
+	l.InfoContext(ctx, "accumulated")
+
+	if expected := []int{1, 7, 15}; !slices.Equal(metrics.attrsPerLines, expected) {
+		t.Errorf("Expected observations %v; Got: %v", expected, metrics.attrsPerLines)
+	}
+}
@@ -0,0 +1,77 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/pazams/yasctx/internal/attr"
+)
+
+type nameKey struct{}
+type addToNameKey struct{}
+type callerKey struct{}
+
+// WithName sets the logger name for all future log lines using the returned context.
+// slog has no concept of a logger name, so the name is tracked in the context instead.
+// Attributes added with AddToName are only included when logging under a matching name.
+func WithName(parent context.Context, name string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, nameKey{}, name)
+}
+
+// AddToName adds the attribute arguments at the root level, but only for log
+// lines using a context whose name (set by WithName) matches the given name.
+func AddToName(parent context.Context, name string, args ...any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	// Copy the map, so that the parent context is not modified
+	v, _ := parent.Value(addToNameKey{}).(map[string][]slog.Attr)
+	m := make(map[string][]slog.Attr, len(v)+1)
+	for k, attrs := range v {
+		m[k] = attrs
+	}
+	m[name] = append(slices.Clip(m[name]), allowedAttrs(parent, attr.ArgsToAttrSlice(args))...)
+	return withFeature(context.WithValue(parent, addToNameKey{}, m), featureAddedToName)
+}
+
+// nameFromCtx returns the name set by WithName, or an empty string.
+func nameFromCtx(ctx context.Context) string {
+	name, _ := ctx.Value(nameKey{}).(string)
+	return name
+}
+
+// extractAddedToName returns the attributes added to the name of the context.
+// The returned slice should not be appended to or modified in any way. Doing so will cause a race condition.
+func extractAddedToName(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if v, ok := ctx.Value(addToNameKey{}).(map[string][]slog.Attr); ok {
+		return v[nameFromCtx(ctx)]
+	}
+	return nil
+}
+
+// WithCaller stores a logical caller or operation name (such as
+// "billing.ChargeCard") in the returned context. Log lines using the context
+// include it as a "caller" attribute. Unlike the source location added by
+// slog.HandlerOptions.AddSource, it is independent of the program counter, so
+// libraries can tag their log lines with a meaningful operation name even when
+// source information is stripped.
+func WithCaller(parent context.Context, name string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return withFeature(context.WithValue(parent, callerKey{}, name), featureCaller)
+}
+
+// extractCaller returns the caller name stored by WithCaller.
+func extractCaller(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if name, ok := ctx.Value(callerKey{}).(string); ok {
+		return []slog.Attr{slog.String("caller", name)}
+	}
+	return nil
+}
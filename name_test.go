@@ -0,0 +1,59 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestAddToName(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := yasctx.Add(context.Background(), "shared", "value")
+	ctx = yasctx.AddToName(ctx, "db", "pool", "primary")
+	ctx = yasctx.AddToName(ctx, "db", "replica", false)
+	ctx = yasctx.AddToName(ctx, "http", "route", "/users")
+	yasctx.AddToName(ctx, "db", "leaked", true) // Ensure we aren't overwriting the parent context
+
+	l.InfoContext(ctx, "no name")
+	l.InfoContext(yasctx.WithName(ctx, "db"), "db name")
+	l.InfoContext(yasctx.WithName(ctx, "http"), "http name")
+	l.InfoContext(yasctx.WithName(ctx, "other"), "other name")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="no name" shared=value
+time=2023-09-29T13:00:59.000Z level=INFO msg="db name" shared=value pool=primary replica=false
+time=2023-09-29T13:00:59.000Z level=INFO msg="http name" shared=value route=/users
+time=2023-09-29T13:00:59.000Z level=INFO msg="other name" shared=value
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestWithCaller(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	l.InfoContext(ctx, "no caller")
+
+	ctx = yasctx.WithCaller(ctx, "billing.ChargeCard")
+	l.InfoContext(ctx, "caller")
+	l.InfoContext(yasctx.WithCaller(ctx, "billing.Refund"), "nested caller")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="no caller" request_id=abc
+time=2023-09-29T13:00:59.000Z level=INFO msg=caller request_id=abc caller=billing.ChargeCard
+time=2023-09-29T13:00:59.000Z level=INFO msg="nested caller" request_id=abc caller=billing.Refund
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,27 @@
+package yasctx
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestNewHandlerWithOptionsUnmodified(t *testing.T) {
+	t.Parallel()
+
+	opts := &HandlerOptions{FlattenGroups: true}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			NewHandlerWithOptions(&test.Handler{}, opts)
+		}()
+	}
+	wg.Wait()
+
+	if opts.GroupSeparator != "" {
+		t.Errorf("Expected the caller's options to be left unmodified; Got GroupSeparator %q", opts.GroupSeparator)
+	}
+}
@@ -0,0 +1,85 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+type testTenantKey struct{}
+
+func TestHandlerMessageTransform(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	h := NewHandlerWithOptions(tester, &HandlerOptions{
+		MessageTransform: func(ctx context.Context, msg string) string {
+			if tenant, ok := ctx.Value(testTenantKey{}).(string); ok {
+				return "[" + tenant + "] " + msg
+			}
+			return msg
+		},
+	})
+
+	ctx := context.WithValue(context.Background(), testTenantKey{}, "acme")
+	slog.New(h).InfoContext(ctx, "main message", "main1", "arg1")
+	slog.New(h).InfoContext(context.Background(), "no tenant")
+	slog.New(NewHandlerWithOptions(tester, &HandlerOptions{})).InfoContext(ctx, "no transform")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="[acme] main message" main1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="no tenant"
+time=2023-09-29T13:00:59.000Z level=INFO msg="no transform"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerAttrLess(t *testing.T) {
+	t.Parallel()
+
+	pinned := map[string]int{"trace_id": 1, "request_id": 2}
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		AttrLess: func(a, b slog.Attr) bool {
+			pa, pb := pinned[a.Key], pinned[b.Key]
+			if pa == 0 || pb == 0 {
+				return pa != 0 && pb == 0
+			}
+			return pa < pb
+		},
+	}))
+
+	ctx := Add(nil, "user_id", 24680, "request_id", "abc", "env", "prod", "trace_id", "xyz")
+	ctx = AddToGroup(ctx, "group1", "table", "users", "request_id", "grouped")
+
+	l.WithGroup("group1").InfoContext(ctx, "main message", "trace_id", "from record")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" trace_id=xyz request_id=abc user_id=24680 env=prod group1.request_id=grouped group1.table=users group1.trace_id="from record"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerStripInternalMarkers(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	ctx := Add(nil, "request_id", "abc", "_ctx_attr_count", 3)
+	ctx = AddToGroup(ctx, "req", "_dropped", 2, "path", "/users")
+
+	for _, strip := range []bool{false, true} {
+		l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{StripInternalMarkers: strip}))
+		l.WithGroup("req").InfoContext(ctx, "main message", "_truncated", true, "size", 10)
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=abc _ctx_attr_count=3 req._dropped=2 req.path=/users req._truncated=true req.size=10
+time=2023-09-29T13:00:59.000Z level=INFO msg="main message" request_id=abc req.path=/users req.size=10
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,45 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+type pathKey struct{}
+
+// maxPathSegments bounds the number of segments kept by PushPath.
+const maxPathSegments = 16
+
+// pathTruncated replaces the oldest segments, once there are more than maxPathSegments.
+const pathTruncated = "..."
+
+// PushPath appends a segment to the path of the context. Log lines using the
+// returned context include a "path" attribute with the segments joined by "/"
+// (such as "/service/handler/db"), a lightweight breadcrumb of how the log
+// line was reached, without full tracing.
+// Calling pop returns the context as it was before the segment was pushed.
+// Only the newest 16 segments are kept, with the older ones replaced by "...".
+func PushPath(parent context.Context, segment string) (ctx context.Context, pop func() context.Context) {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	v, _ := parent.Value(pathKey{}).([]string)
+	// Clip to ensure this is a scoped copy
+	v = append(slices.Clip(v), segment)
+	if len(v) > maxPathSegments {
+		v = append([]string{pathTruncated}, v[len(v)-maxPathSegments+1:]...)
+	}
+	return withFeature(context.WithValue(parent, pathKey{}, v), featurePath), func() context.Context { return parent }
+}
+
+// extractPath returns the path built by PushPath.
+func extractPath(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if v, ok := ctx.Value(pathKey{}).([]string); ok {
+		return []slog.Attr{slog.String("path", "/"+strings.Join(v, "/"))}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package yasctx_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestPushPath(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx, _ := yasctx.PushPath(context.Background(), "service")
+	ctx, popHandler := yasctx.PushPath(ctx, "handler")
+	dbCtx, popDB := yasctx.PushPath(ctx, "db")
+	l.InfoContext(dbCtx, "query")
+
+	ctx = popDB()
+	l.InfoContext(ctx, "queried")
+	ctx = popHandler()
+	l.InfoContext(ctx, "handled")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=query path=/service/handler/db
+time=2023-09-29T13:00:59.000Z level=INFO msg=queried path=/service/handler
+time=2023-09-29T13:00:59.000Z level=INFO msg=handled path=/service
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestPushPathBounded(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		ctx, _ = yasctx.PushPath(ctx, fmt.Sprint(i))
+	}
+	l.InfoContext(ctx, "deep")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=deep path=/.../5/6/7/8/9/10/11/12/13/14/15/16/17/18/19
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,116 @@
+package yasctx
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestHandlerExtractorNames(t *testing.T) {
+	t.Parallel()
+
+	dynamic := &Dynamic{}
+	h := NewHandlerWithOptions(&test.Handler{}, &HandlerOptions{
+		Prependers: []AttrExtractor{dynamic.Extractor()},
+		Appenders:  []AttrExtractor{extractAdded},
+	})
+
+	names := h.ExtractorNames()
+	expected := []string{
+		"yasctx.extractPropagatedAttrs",
+		"yasctx.extractAdded",
+		"yasctx.extractFeatures",
+		"yasctx.(*Dynamic).Extractor.func1",
+		"yasctx.extractAdded",
+	}
+	if !slices.Equal(names, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v\n", expected, names)
+	}
+
+	// Derived handlers keep the same extractors
+	if derived := h.WithGroup("group1").(*Handler).ExtractorNames(); !slices.Equal(derived, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v\n", expected, derived)
+	}
+}
+
+func TestHandlerPerLevel(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		Prependers: []AttrExtractor{StaticExtractor("set", "default")},
+		PerLevel: map[slog.Level][]AttrExtractor{
+			slog.LevelInfo:  {StaticExtractor("set", "minimal")},
+			slog.LevelError: {StaticExtractor("set", "rich", "host", "api-1", "build", "abc123")},
+		},
+	}))
+
+	ctx := Add(nil, "request_id", "abc")
+	l.DebugContext(ctx, "debug")
+	l.InfoContext(ctx, "info")
+	l.WarnContext(ctx, "warn")
+	l.ErrorContext(ctx, "error")
+	l.Log(ctx, slog.LevelError+4, "fatal")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=DEBUG msg=debug request_id=abc set=default
+time=2023-09-29T13:00:59.000Z level=INFO msg=info request_id=abc set=minimal
+time=2023-09-29T13:00:59.000Z level=WARN msg=warn request_id=abc set=minimal
+time=2023-09-29T13:00:59.000Z level=ERROR msg=error request_id=abc set=rich host=api-1 build=abc123
+time=2023-09-29T13:00:59.000Z level=ERROR+4 msg=fatal request_id=abc set=rich host=api-1 build=abc123
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestHandlerAppenderOrdering(t *testing.T) {
+	t.Parallel()
+
+	appenders := []AttrExtractor{
+		StaticExtractor("app1", "arg1"),
+		StaticExtractor("app2", "arg1"),
+		StaticExtractor("app3", "arg1"),
+	}
+
+	for _, tc := range []struct {
+		reverse  bool
+		before   bool
+		expected string
+	}{
+		{
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1.main1=arg1 app1=arg1 app2=arg1 app3=arg1
+`,
+		},
+		{
+			reverse: true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1.main1=arg1 app3=arg1 app2=arg1 app1=arg1
+`,
+		},
+		{
+			before: true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 app1=arg1 app2=arg1 app3=arg1 group1.main1=arg1
+`,
+		},
+		{
+			reverse: true,
+			before:  true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 app3=arg1 app2=arg1 app1=arg1 group1.main1=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+			Appenders:             appenders,
+			ReverseAppenders:      tc.reverse,
+			AppendersBeforeRecord: tc.before,
+		}))
+
+		l.WithGroup("group1").InfoContext(Add(nil, "ctx1", "arg1"), "main message", "main1", "arg1")
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("Reverse %v before %v expected:\n%s\nGot:\n%s\n", tc.reverse, tc.before, tc.expected, s)
+		}
+	}
+}
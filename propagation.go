@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pazams/yasctx/internal/attr"
@@ -21,9 +22,7 @@ func InitPropagation(parent context.Context) context.Context {
 		return parent
 	}
 
-	m := &syncOrderedMap{
-		kv: map[string]slog.Attr{},
-	}
+	m := newSyncOrderedMap()
 	if parent == nil {
 		parent = context.Background()
 	}
@@ -36,6 +35,9 @@ func InitPropagation(parent context.Context) context.Context {
 func AddWithPropagation(ctx context.Context, args ...any) context.Context {
 	// Convert args to a slice of slog.Attr
 	attrs := attr.ArgsToAttrSlice(args)
+	if ctx != nil {
+		attrs = allowedAttrs(ctx, attrs)
+	}
 	if len(attrs) == 0 {
 		return ctx
 	}
@@ -91,6 +93,21 @@ type syncOrderedMap struct {
 	mu    sync.RWMutex
 	kv    map[string]slog.Attr
 	order []string
+	*collectorState
+}
+
+// collectorState is the state of a collector other than its attributes, which
+// is shared with the views of it that hide the attributes (see withoutAttrs).
+type collectorState struct {
+	seq  atomic.Uint64 // Used by SequenceContext
+	last atomic.Int64  // Used by SinceLastExtractor, in Unix nanoseconds
+	idMu sync.Mutex
+	id   string // Used by ContextID, guarded by idMu
+}
+
+// newSyncOrderedMap returns an empty collector.
+func newSyncOrderedMap() *syncOrderedMap {
+	return &syncOrderedMap{kv: map[string]slog.Attr{}, collectorState: &collectorState{}}
 }
 
 // ctxKey is how we find our attribute collector data structure in the context
@@ -0,0 +1,446 @@
+package yasctx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+)
+
+// binaryVersion is the first byte of every binary encoded payload.
+// It lets future versions of the format be decoded alongside older ones.
+const binaryVersion byte = 1
+
+// binaryVersionSections is the version of payloads made of tagged sections,
+// written by MarshalContextBinary. Each section is a tag byte followed by its
+// attributes.
+const binaryVersionSections byte = 2
+
+// The tags of the sections of a binaryVersionSections payload.
+const (
+	binarySectionPropagated byte = 1
+	binarySectionLocal      byte = 2
+)
+
+// maxBinaryGroupDepth bounds the nesting of groups when decoding, so that a
+// malicious payload can not exhaust the stack.
+const maxBinaryGroupDepth = 32
+
+// PropagationKey is the key under which InjectMap stores the propagated
+// attributes, and from which ExtractMap reads them.
+const PropagationKey = "yasctx-propagation"
+
+// ErrInvalidBinary is returned when a binary payload can not be decoded.
+var ErrInvalidBinary = errors.New("yasctx: invalid binary propagation payload")
+
+// PropagationOptions are options for serializing propagated attributes
+type PropagationOptions struct {
+	// AllowedKeys limits the serialized attributes to only those with these keys
+	// (such as "trace_id" and "request_id"), preventing sensitive local context
+	// from accidentally crossing the boundary.
+	// If nil, all propagated attributes are serialized.
+	AllowedKeys []string
+
+	// Sampled, if set, reports whether the current trace is sampled. When it
+	// returns false, nothing is serialized, which avoids the overhead of
+	// propagation for unsampled requests. For example, with OpenTelemetry:
+	//
+	//	Sampled: func(ctx context.Context) bool {
+	//		return trace.SpanContextFromContext(ctx).IsSampled()
+	//	}
+	Sampled func(ctx context.Context) bool
+
+	// IncludeLocal causes MarshalContextBinary to also encode the attributes
+	// added locally with Add, which are otherwise kept out of the payload.
+	// Anyone holding the payload can read them with UnmarshalLocalBinary, so
+	// use it with AllowedKeys to limit which local attributes are sent.
+	IncludeLocal bool
+}
+
+// MarshalPropagatedBinary encodes the attributes added with AddWithPropagation
+// into a compact binary form, suitable for headers or message metadata in
+// high-throughput systems.
+// LogValuer values are resolved before encoding, and values of KindAny are
+// encoded as their string representation.
+// It returns nil if propagation wasn't initialized on the context or no attributes were added.
+func MarshalPropagatedBinary(ctx context.Context) ([]byte, error) {
+	return MarshalPropagatedBinaryWithOptions(ctx, nil)
+}
+
+// MarshalPropagatedBinaryWithOptions encodes the propagated attributes like
+// MarshalPropagatedBinary, configured by opts.
+// If opts is nil, the default options are used.
+func MarshalPropagatedBinaryWithOptions(ctx context.Context, opts *PropagationOptions) ([]byte, error) {
+	attrs := propagatedAttrsToSerialize(ctx, opts)
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	return appendBinaryAttrs([]byte{binaryVersion}, attrs, 0)
+}
+
+// MarshalContextBinary encodes the attributes added with AddWithPropagation,
+// and, if opts.IncludeLocal is set, those added locally with Add, in separate
+// tagged sections. UnmarshalPropagatedBinary restores only the propagated
+// section, so local attributes are not added to the receiver's context, but
+// they are in the payload, and can be read with UnmarshalLocalBinary (such as
+// to record where a message came from).
+// The options apply to both sections. If opts is nil, the default options are
+// used, and no local attributes are encoded.
+// It returns nil if there are no attributes to encode.
+func MarshalContextBinary(ctx context.Context, opts *PropagationOptions) ([]byte, error) {
+	propagated := propagatedAttrsToSerialize(ctx, opts)
+	var local []slog.Attr
+	if opts != nil && opts.IncludeLocal && (opts.Sampled == nil || opts.Sampled(ctx)) {
+		local = filterAllowedKeys(slices.Clone(extractAdded(ctx, time.Time{}, 0, "")), opts)
+	}
+	if len(propagated) == 0 && len(local) == 0 {
+		return nil, nil
+	}
+
+	b := []byte{binaryVersionSections}
+	var err error
+	for _, section := range []struct {
+		tag   byte
+		attrs []slog.Attr
+	}{{binarySectionPropagated, propagated}, {binarySectionLocal, local}} {
+		if len(section.attrs) == 0 {
+			continue
+		}
+		if b, err = appendBinaryAttrs(append(b, section.tag), section.attrs, 0); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// propagatedAttrsToSerialize returns the propagated attributes that are allowed to cross the boundary.
+func propagatedAttrsToSerialize(ctx context.Context, opts *PropagationOptions) []slog.Attr {
+	if opts != nil && opts.Sampled != nil && !opts.Sampled(ctx) {
+		return nil
+	}
+	return filterAllowedKeys(extractPropagatedAttrs(ctx, time.Time{}, 0, ""), opts)
+}
+
+// filterAllowedKeys returns the attributes whose keys are in the AllowedKeys of opts.
+// The attributes are filtered in place.
+func filterAllowedKeys(attrs []slog.Attr, opts *PropagationOptions) []slog.Attr {
+	if opts == nil || opts.AllowedKeys == nil {
+		return attrs
+	}
+	return slices.DeleteFunc(attrs, func(a slog.Attr) bool {
+		return !slices.Contains(opts.AllowedKeys, a.Key)
+	})
+}
+
+// UnmarshalPropagatedBinary decodes a payload created by MarshalPropagatedBinary
+// or MarshalContextBinary, and adds the propagated attributes to the context
+// with AddWithPropagation. Local attributes are not added.
+func UnmarshalPropagatedBinary(ctx context.Context, data []byte) (context.Context, error) {
+	if len(data) == 0 {
+		return ctx, nil
+	}
+	attrs, err := readBinarySection(data, binarySectionPropagated)
+	if err != nil {
+		return ctx, err
+	}
+	if len(attrs) == 0 {
+		return ctx, nil
+	}
+
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	return AddWithPropagation(ctx, args...), nil
+}
+
+// UnmarshalLocalBinary decodes the local attributes of a payload created by
+// MarshalContextBinary with PropagationOptions.IncludeLocal, without adding
+// them to any context.
+func UnmarshalLocalBinary(data []byte) ([]slog.Attr, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return readBinarySection(data, binarySectionLocal)
+}
+
+// readBinarySection returns the attributes of the section with the tag, after
+// validating the whole payload. Payloads of binaryVersion only have propagated
+// attributes.
+func readBinarySection(data []byte, tag byte) ([]slog.Attr, error) {
+	switch data[0] {
+	case binaryVersion:
+		attrs, rest, err := readBinaryAttrs(data[1:], 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) != 0 {
+			return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidBinary, len(rest))
+		}
+		if tag != binarySectionPropagated {
+			return nil, nil
+		}
+		return attrs, nil
+
+	case binaryVersionSections:
+		var found []slog.Attr
+		var last byte
+		for data = data[1:]; len(data) > 0; {
+			// Sections are written once each, in the order of their tags
+			if data[0] <= last || data[0] > binarySectionLocal {
+				return nil, fmt.Errorf("%w: unexpected section %d", ErrInvalidBinary, data[0])
+			}
+			last = data[0]
+			attrs, rest, err := readBinaryAttrs(data[1:], 0)
+			if err != nil {
+				return nil, err
+			}
+			if last == tag {
+				found = attrs
+			}
+			data = rest
+		}
+		return found, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidBinary, data[0])
+	}
+}
+
+// InjectMap returns a map holding the propagated attributes, ready to be used
+// as message queue headers (such as Kafka, NATS, or SQS).
+// The attributes are stored under PropagationKey, in the binary encoding of
+// MarshalPropagatedBinary, as unpadded base64url text.
+// It returns an empty map if there is nothing to propagate or the attributes can not be encoded.
+func InjectMap(ctx context.Context) map[string]string {
+	return InjectMapWithOptions(ctx, nil)
+}
+
+// InjectMapWithOptions returns a map holding the propagated attributes like
+// InjectMap, configured by opts.
+// If opts is nil, the default options are used.
+func InjectMapWithOptions(ctx context.Context, opts *PropagationOptions) map[string]string {
+	m := map[string]string{}
+	data, err := MarshalPropagatedBinaryWithOptions(ctx, opts)
+	if err != nil || len(data) == 0 {
+		return m
+	}
+	m[PropagationKey] = base64.RawURLEncoding.EncodeToString(data)
+	return m
+}
+
+// ExtractMap reads the propagated attributes stored by InjectMap, and adds
+// them to the context with AddWithPropagation.
+// If the map does not hold valid propagated attributes, the context is returned unchanged.
+func ExtractMap(ctx context.Context, m map[string]string) context.Context {
+	s, ok := m[PropagationKey]
+	if !ok {
+		return ctx
+	}
+	return extractPropagationText(ctx, s)
+}
+
+// extractPropagationText adds the propagated attributes encoded by InjectMap in s to the context.
+// If s does not hold valid propagated attributes, the context is returned unchanged.
+func extractPropagationText(ctx context.Context, s string) context.Context {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ctx
+	}
+	newCtx, err := UnmarshalPropagatedBinary(ctx, data)
+	if err != nil {
+		return ctx
+	}
+	return newCtx
+}
+
+// appendBinaryAttrs appends the count of attributes followed by each attribute.
+func appendBinaryAttrs(b []byte, attrs []slog.Attr, depth int) ([]byte, error) {
+	if depth > maxBinaryGroupDepth {
+		return nil, fmt.Errorf("%w: groups nested deeper than %d", ErrInvalidBinary, maxBinaryGroupDepth)
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(attrs)))
+	for _, a := range attrs {
+		var err error
+		b = appendBinaryString(b, a.Key)
+		if b, err = appendBinaryValue(b, a.Value.Resolve(), depth); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendBinaryValue appends the kind of the value followed by its encoding.
+func appendBinaryValue(b []byte, v slog.Value, depth int) ([]byte, error) {
+	switch v.Kind() {
+	case slog.KindBool:
+		b = append(b, byte(slog.KindBool))
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+
+	case slog.KindDuration:
+		b = append(b, byte(slog.KindDuration))
+		return binary.AppendVarint(b, int64(v.Duration())), nil
+
+	case slog.KindFloat64:
+		b = append(b, byte(slog.KindFloat64))
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float64())), nil
+
+	case slog.KindInt64:
+		b = append(b, byte(slog.KindInt64))
+		return binary.AppendVarint(b, v.Int64()), nil
+
+	case slog.KindUint64:
+		b = append(b, byte(slog.KindUint64))
+		return binary.AppendUvarint(b, v.Uint64()), nil
+
+	case slog.KindString:
+		b = append(b, byte(slog.KindString))
+		return appendBinaryString(b, v.String()), nil
+
+	case slog.KindTime:
+		t, err := v.Time().MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, byte(slog.KindTime))
+		b = binary.AppendUvarint(b, uint64(len(t)))
+		return append(b, t...), nil
+
+	case slog.KindGroup:
+		b = append(b, byte(slog.KindGroup))
+		return appendBinaryAttrs(b, v.Group(), depth+1)
+
+	default:
+		// KindAny can hold anything, so it crosses the boundary as a string
+		b = append(b, byte(slog.KindString))
+		return appendBinaryString(b, v.String()), nil
+	}
+}
+
+func appendBinaryString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readBinaryAttrs reads attributes written by appendBinaryAttrs, returning the unconsumed bytes.
+func readBinaryAttrs(data []byte, depth int) ([]slog.Attr, []byte, error) {
+	if depth > maxBinaryGroupDepth {
+		return nil, nil, fmt.Errorf("%w: groups nested deeper than %d", ErrInvalidBinary, maxBinaryGroupDepth)
+	}
+
+	n, data, err := readBinaryUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Each attribute needs at least a key length and a kind byte
+	if n > uint64(len(data))/2 {
+		return nil, nil, fmt.Errorf("%w: attribute count %d exceeds payload", ErrInvalidBinary, n)
+	}
+
+	attrs := make([]slog.Attr, 0, n)
+	for i := uint64(0); i < n; i++ {
+		var key string
+		var val slog.Value
+		if key, data, err = readBinaryString(data); err != nil {
+			return nil, nil, err
+		}
+		if val, data, err = readBinaryValue(data, depth); err != nil {
+			return nil, nil, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: val})
+	}
+	return attrs, data, nil
+}
+
+// readBinaryValue reads a value written by appendBinaryValue, returning the unconsumed bytes.
+func readBinaryValue(data []byte, depth int) (slog.Value, []byte, error) {
+	if len(data) == 0 {
+		return slog.Value{}, nil, fmt.Errorf("%w: missing kind", ErrInvalidBinary)
+	}
+	kind := slog.Kind(data[0])
+	data = data[1:]
+
+	switch kind {
+	case slog.KindBool:
+		if len(data) == 0 {
+			return slog.Value{}, nil, fmt.Errorf("%w: missing bool", ErrInvalidBinary)
+		}
+		return slog.BoolValue(data[0] != 0), data[1:], nil
+
+	case slog.KindDuration:
+		i, rest, err := readBinaryVarint(data)
+		return slog.DurationValue(time.Duration(i)), rest, err
+
+	case slog.KindFloat64:
+		if len(data) < 8 {
+			return slog.Value{}, nil, fmt.Errorf("%w: short float64", ErrInvalidBinary)
+		}
+		return slog.Float64Value(math.Float64frombits(binary.LittleEndian.Uint64(data))), data[8:], nil
+
+	case slog.KindInt64:
+		i, rest, err := readBinaryVarint(data)
+		return slog.Int64Value(i), rest, err
+
+	case slog.KindUint64:
+		u, rest, err := readBinaryUvarint(data)
+		return slog.Uint64Value(u), rest, err
+
+	case slog.KindString:
+		s, rest, err := readBinaryString(data)
+		return slog.StringValue(s), rest, err
+
+	case slog.KindTime:
+		s, rest, err := readBinaryString(data)
+		if err != nil {
+			return slog.Value{}, nil, err
+		}
+		var t time.Time
+		if err = t.UnmarshalBinary([]byte(s)); err != nil {
+			return slog.Value{}, nil, fmt.Errorf("%w: %w", ErrInvalidBinary, err)
+		}
+		return slog.TimeValue(t), rest, nil
+
+	case slog.KindGroup:
+		attrs, rest, err := readBinaryAttrs(data, depth+1)
+		return slog.GroupValue(attrs...), rest, err
+
+	default:
+		return slog.Value{}, nil, fmt.Errorf("%w: unknown kind %d", ErrInvalidBinary, kind)
+	}
+}
+
+func readBinaryString(data []byte) (string, []byte, error) {
+	n, data, err := readBinaryUvarint(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(data)) {
+		return "", nil, fmt.Errorf("%w: string length %d exceeds payload", ErrInvalidBinary, n)
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func readBinaryUvarint(data []byte) (uint64, []byte, error) {
+	u, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("%w: bad uvarint", ErrInvalidBinary)
+	}
+	return u, data[n:], nil
+}
+
+func readBinaryVarint(data []byte) (int64, []byte, error) {
+	i, n := binary.Varint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("%w: bad varint", ErrInvalidBinary)
+	}
+	return i, data[n:], nil
+}
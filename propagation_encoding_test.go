@@ -0,0 +1,334 @@
+package yasctx_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+type testLogValuer struct{}
+
+func (testLogValuer) LogValue() slog.Value {
+	return slog.StringValue("resolved")
+}
+
+func TestPropagatedBinaryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 9, 29, 13, 0, 59, 123, time.FixedZone("test", 3600))
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx,
+		slog.Bool("bool", true),
+		slog.Duration("duration", 3*time.Second),
+		slog.Float64("float64", -1.5),
+		slog.Int64("int64", -42),
+		slog.String("string", "hello"),
+		slog.Time("time", now),
+		slog.Uint64("uint64", 42),
+		slog.Group("group", slog.String("inner", "value"), slog.Group("nested", slog.Int("deep", 1))),
+		slog.Any("any", errors.New("boom")),
+		slog.Any("logvaluer", testLogValuer{}),
+	)
+
+	data, err := yasctx.MarshalPropagatedBinary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	restored, err := yasctx.UnmarshalPropagatedBinary(yasctx.InitPropagation(context.Background()), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.InfoContext(restored, "restored")
+
+	expected := `{"time":"2023-09-29T13:00:59Z","level":"INFO","msg":"restored","bool":true,"duration":3000000000,"float64":-1.5,"int64":-42,"string":"hello","time":"2023-09-29T13:00:59.000000123+01:00","uint64":42,"group":{"inner":"value","nested":{"deep":1}},"any":"boom","logvaluer":"resolved"}
+`
+	jsn, err := tester.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(jsn) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, string(jsn))
+	}
+
+	// Check the kinds survived the round trip
+	var kinds []slog.Kind
+	tester.Records[0].Attrs(func(a slog.Attr) bool {
+		kinds = append(kinds, a.Value.Kind())
+		return true
+	})
+	expectedKinds := []slog.Kind{
+		slog.KindBool, slog.KindDuration, slog.KindFloat64, slog.KindInt64, slog.KindString,
+		slog.KindTime, slog.KindUint64, slog.KindGroup, slog.KindString, slog.KindString,
+	}
+	if len(kinds) != len(expectedKinds) {
+		t.Fatalf("Expected kinds %v; Got: %v", expectedKinds, kinds)
+	}
+	for i := range kinds {
+		if kinds[i] != expectedKinds[i] {
+			t.Errorf("Expected kinds %v; Got: %v", expectedKinds, kinds)
+			break
+		}
+	}
+}
+
+func TestPropagatedBinaryEmpty(t *testing.T) {
+	t.Parallel()
+
+	data, err := yasctx.MarshalPropagatedBinary(context.Background())
+	if err != nil || data != nil {
+		t.Errorf("Expected nil payload without propagation; Got: %v %v", data, err)
+	}
+
+	ctx, err := yasctx.UnmarshalPropagatedBinary(context.Background(), nil)
+	if err != nil || ctx != context.Background() {
+		t.Errorf("Expected unchanged context for empty payload; Got: %v %v", ctx, err)
+	}
+}
+
+func TestPropagatedBinaryInvalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "key", "value", slog.Group("group", "inner", 1))
+	data, err := yasctx.MarshalPropagatedBinary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string][]byte{
+		"version":   append([]byte{99}, data[1:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte{}, data...), 0),
+		"count":     {1, 0xff, 0xff, 0xff, 0x0f},
+	}
+	for name, payload := range invalid {
+		if _, err := yasctx.UnmarshalPropagatedBinary(context.Background(), payload); !errors.Is(err, yasctx.ErrInvalidBinary) {
+			t.Errorf("%s: expected ErrInvalidBinary; Got: %v", name, err)
+		}
+	}
+}
+
+func TestPropagatedBinaryAllowedKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "abc", "password", "hunter2", "request_id", 123)
+
+	data, err := yasctx.MarshalPropagatedBinaryWithOptions(ctx, &yasctx.PropagationOptions{
+		AllowedKeys: []string{"request_id", "trace_id", "missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "password") || strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected non-allowed attribute to be excluded from payload: %q", data)
+	}
+
+	tester := &test.Handler{}
+	restored, err := yasctx.UnmarshalPropagatedBinary(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(yasctx.NewHandler(tester)).InfoContext(restored, "restored")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=restored trace_id=abc request_id=123
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Nothing allowed means nothing is serialized
+	data, err = yasctx.MarshalPropagatedBinaryWithOptions(ctx, &yasctx.PropagationOptions{AllowedKeys: []string{}})
+	if err != nil || data != nil {
+		t.Errorf("Expected nil payload when no keys are allowed; Got: %v %v", data, err)
+	}
+}
+
+func TestContextBinarySections(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "abc", "tenant", "acme")
+	ctx = yasctx.Add(ctx, "local_only", "secret", "handler", "orders")
+
+	data, err := yasctx.MarshalContextBinary(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the propagated attributes cross the boundary
+	tester := &test.Handler{}
+	restored, err := yasctx.UnmarshalPropagatedBinary(yasctx.InitPropagation(context.Background()), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(yasctx.NewHandler(tester)).InfoContext(restored, "restored")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=restored trace_id=abc tenant=acme
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Local attributes are not encoded by default
+	if local, err := yasctx.UnmarshalLocalBinary(data); err != nil || local != nil {
+		t.Errorf("Expected no local attributes; Got: %v %v", local, err)
+	}
+
+	// Once included, the allowed local attributes can be read explicitly
+	data, err = yasctx.MarshalContextBinary(ctx, &yasctx.PropagationOptions{
+		AllowedKeys:  []string{"trace_id", "tenant", "handler"},
+		IncludeLocal: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := yasctx.UnmarshalLocalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := slog.GroupValue(local...).String(); s != "[handler=orders]" {
+		t.Errorf("Expected the local attributes; Got: %s", s)
+	}
+
+	// Payloads of MarshalPropagatedBinary have no local attributes
+	data, err = yasctx.MarshalPropagatedBinary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local, err := yasctx.UnmarshalLocalBinary(data); err != nil || local != nil {
+		t.Errorf("Expected no local attributes; Got: %v %v", local, err)
+	}
+
+	// A context with only local attributes propagates nothing
+	data, err = yasctx.MarshalContextBinary(yasctx.Add(context.Background(), "local_only", "secret"), &yasctx.PropagationOptions{IncludeLocal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := yasctx.UnmarshalPropagatedBinary(context.Background(), data); err != nil || restored != context.Background() {
+		t.Errorf("Expected unchanged context; Got: %v %v", restored, err)
+	}
+
+	invalid := map[string][]byte{
+		"unknown section":   {2, 3, 0},
+		"repeated section":  {2, 1, 0, 1, 0},
+		"unordered section": {2, 2, 0, 1, 0},
+		"truncated section": {2, 1, 1},
+	}
+	for name, payload := range invalid {
+		if _, err := yasctx.UnmarshalPropagatedBinary(context.Background(), payload); !errors.Is(err, yasctx.ErrInvalidBinary) {
+			t.Errorf("%s: expected ErrInvalidBinary; Got: %v", name, err)
+		}
+	}
+}
+
+func TestInjectExtractMap(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "abc", "attempt", 2, "secret", "hunter2")
+
+	// Simulate a producer setting the message headers
+	headers := yasctx.InjectMapWithOptions(ctx, &yasctx.PropagationOptions{AllowedKeys: []string{"trace_id", "attempt"}})
+	headers["content-type"] = "application/json"
+
+	if len(headers) != 2 || headers[yasctx.PropagationKey] == "" {
+		t.Fatalf("Expected propagation header; Got: %v", headers)
+	}
+
+	// Simulate a consumer receiving the message
+	tester := &test.Handler{}
+	consumerCtx := yasctx.ExtractMap(yasctx.InitPropagation(context.Background()), headers)
+	slog.New(yasctx.NewHandler(tester)).InfoContext(consumerCtx, "consumed")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=consumed trace_id=abc attempt=2
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Nothing to inject, and nothing to extract
+	if headers := yasctx.InjectMap(context.Background()); len(headers) != 0 {
+		t.Errorf("Expected empty map; Got: %v", headers)
+	}
+	for _, headers := range []map[string]string{nil, {yasctx.PropagationKey: "!!!"}, {yasctx.PropagationKey: "AQ"}} {
+		if got := yasctx.ExtractMap(context.Background(), headers); got != context.Background() {
+			t.Errorf("Expected unchanged context for %v", headers)
+		}
+	}
+}
+
+type sampledKey struct{}
+
+func TestPropagationSampled(t *testing.T) {
+	t.Parallel()
+
+	opts := &yasctx.PropagationOptions{
+		Sampled: func(ctx context.Context) bool {
+			sampled, _ := ctx.Value(sampledKey{}).(bool)
+			return sampled
+		},
+	}
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "abc")
+
+	unsampled := context.WithValue(ctx, sampledKey{}, false)
+	if data, err := yasctx.MarshalPropagatedBinaryWithOptions(unsampled, opts); err != nil || data != nil {
+		t.Errorf("Expected nil payload when unsampled; Got: %v %v", data, err)
+	}
+	if headers := yasctx.InjectMapWithOptions(unsampled, opts); len(headers) != 0 {
+		t.Errorf("Expected no headers when unsampled; Got: %v", headers)
+	}
+
+	sampled := context.WithValue(ctx, sampledKey{}, true)
+	if data, err := yasctx.MarshalPropagatedBinaryWithOptions(sampled, opts); err != nil || len(data) == 0 {
+		t.Errorf("Expected payload when sampled; Got: %v %v", data, err)
+	}
+	if headers := yasctx.InjectMapWithOptions(sampled, opts); len(headers) != 1 {
+		t.Errorf("Expected headers when sampled; Got: %v", headers)
+	}
+}
+
+func BenchmarkPropagatedBinary(b *testing.B) {
+	attrs := []any{
+		slog.String("request_id", "4bf92f3577b34da6a3ce929d0e0e4736"),
+		slog.Int("user_id", 24680),
+		slog.String("tenant", "acme"),
+		slog.Bool("sampled", true),
+		slog.Time("started", time.Date(2023, 9, 29, 13, 0, 59, 0, time.UTC)),
+	}
+	ctx := yasctx.AddWithPropagation(yasctx.InitPropagation(context.Background()), attrs...)
+
+	b.Run("binary", func(b *testing.B) {
+		var data []byte
+		for i := 0; i < b.N; i++ {
+			data, _ = yasctx.MarshalPropagatedBinary(ctx)
+		}
+		b.ReportMetric(float64(len(data)), "payload-bytes")
+	})
+
+	b.Run("json", func(b *testing.B) {
+		var data []byte
+		for i := 0; i < b.N; i++ {
+			m := make(map[string]any, len(attrs))
+			for _, a := range attrs {
+				m[a.(slog.Attr).Key] = a.(slog.Attr).Value.Any()
+			}
+			data, _ = json.Marshal(m)
+		}
+		b.ReportMetric(float64(len(data)), "payload-bytes")
+	})
+}
@@ -0,0 +1,82 @@
+package yasctx
+
+import (
+	"context"
+	"time"
+)
+
+// rebasedKeys are the context keys whose values are immutable, and so can be
+// shared by Rebase between the old and new contexts.
+var rebasedKeys = []any{
+	addKey{},
+	addToGroupKey{},
+	addTextOnlyKey{},
+	allowedKeysKey{},
+	auditKey{},
+	computedKey{},
+	contextIDKey{},
+	stackKey{},
+	errorKey{},
+	featuresKey{},
+	headersKey{},
+	internKeysKey{},
+	nameKey{},
+	addToNameKey{},
+	callerKey{},
+	clockKey{},
+	compactionKey{},
+	flagsKey{},
+	groupAddModeKey{},
+	levelOverrideKey{},
+	tenantKey{},
+	verboseKey{},
+	pathKey{},
+	sampleSeedKey{},
+	spansKey{},
+	startKey{},
+	transformsKey{},
+	ttlKey{},
+}
+
+// Rebase returns a context derived from newBase, holding all of the logging
+// context found in ctx. This is useful when starting a new operation (such as
+// a background job kicked off by a request) that should inherit the logging
+// context, but not the other values of the old context, such as its
+// cancellation and deadline.
+// Attributes propagated with AddWithPropagation are copied into a new
+// collector, so that attributes propagated later in either context do not
+// affect the other. The id assigned by ContextID is kept.
+// If FirstErrorOnly is used, the new context logs its own first error.
+func Rebase(ctx context.Context, newBase context.Context) context.Context {
+	if newBase == nil {
+		newBase = context.Background()
+	}
+	if ctx == nil {
+		return newBase
+	}
+
+	for _, key := range rebasedKeys {
+		if v := ctx.Value(key); v != nil {
+			newBase = context.WithValue(newBase, key, v)
+		}
+	}
+
+	if ctx.Value(firstErrorKey{}) != nil {
+		newBase = context.WithValue(newBase, firstErrorKey{}, &firstError{})
+	}
+
+	if m := fromCtx(ctx); m != nil {
+		n := newSyncOrderedMap()
+		m.idMu.Lock()
+		n.id = m.id
+		m.idMu.Unlock()
+		newBase = context.WithValue(newBase, ctxKey{}, n)
+		attrs := extractPropagatedAttrs(ctx, time.Time{}, 0, "")
+		args := make([]any, len(attrs))
+		for i, a := range attrs {
+			args[i] = a
+		}
+		newBase = AddWithPropagation(newBase, args...)
+	}
+	return newBase
+}
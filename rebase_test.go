@@ -0,0 +1,60 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestRebase(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx, cancel := context.WithTimeout(yasctx.InitPropagation(context.Background()), time.Hour)
+	ctx = yasctx.Add(ctx, "request_id", "abc")
+	ctx = yasctx.AddToGroup(ctx, "job", "kind", "email")
+	ctx = yasctx.WithCaller(ctx, "api.Signup")
+	ctx = yasctx.AddWithPropagation(ctx, "user_id", 24680)
+
+	rebased := yasctx.Rebase(ctx, context.Background())
+	cancel()
+
+	if rebased.Err() != nil {
+		t.Errorf("Expected rebased context to not be canceled; Got: %v", rebased.Err())
+	}
+	if _, ok := rebased.Deadline(); ok {
+		t.Error("Expected rebased context to not have a deadline")
+	}
+
+	// Propagating later does not cross between the contexts
+	yasctx.AddWithPropagation(ctx, "late", "old")
+	yasctx.AddWithPropagation(rebased, "late", "new")
+
+	l.WithGroup("job").InfoContext(rebased, "background job")
+	l.InfoContext(ctx, "request done")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="background job" user_id=24680 late=new request_id=abc caller=api.Signup job.kind=email
+time=2023-09-29T13:00:59.000Z level=INFO msg="request done" user_id=24680 late=old request_id=abc caller=api.Signup kind=email
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestRebaseNewBase(t *testing.T) {
+	t.Parallel()
+
+	newBase, cancel := context.WithCancel(context.Background())
+	rebased := yasctx.Rebase(yasctx.Add(context.Background(), "key", "value"), newBase)
+
+	cancel()
+	if rebased.Err() == nil {
+		t.Error("Expected rebased context to be canceled with its new base")
+	}
+}
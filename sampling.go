@@ -0,0 +1,55 @@
+package yasctx
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+type sampleSeedKey struct{}
+
+// WithSampleSeed sets the seed used by HandlerOptions.SampleRate to decide
+// whether to keep the log lines using the returned context (such as a request
+// or trace id). The decision is the same for every log line with the same
+// seed, so a request is either logged in full or not at all, rather than
+// leaving partial request logs.
+func WithSampleSeed(parent context.Context, seed string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, sampleSeedKey{}, seed)
+}
+
+// sampled reports whether a log line using the context is kept, at the rate.
+// Log lines without a seed are sampled independently of each other.
+func sampled(ctx context.Context, rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	seed, ok := ctx.Value(sampleSeedKey{}).(string)
+	if !ok {
+		return rand.Float64() < rate
+	}
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return float64(mix64(h.Sum64()))/math.MaxUint64 < rate
+}
+
+// keySampled reports whether an attribute sampled by HandlerOptions.KeySampleRates
+// is kept on a log line, at the rate.
+func keySampled(rate float64) bool {
+	return rate <= 0 || rate >= 1 || rand.Float64() < rate
+}
+
+// mix64 spreads the bits of the hash, because the high bits of FNV barely
+// change for seeds that differ only in their last bytes (such as sequential ids).
+// It is the finalizer of MurmurHash3.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
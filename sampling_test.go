@@ -0,0 +1,98 @@
+package yasctx_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestWithSampleSeed(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{SampleRate: 0.5}))
+
+	const requests, linesPerRequest = 200, 5
+	for i := 0; i < requests; i++ {
+		ctx := yasctx.WithSampleSeed(context.Background(), fmt.Sprintf("request-%d", i))
+		ctx = yasctx.Add(ctx, "request", i)
+		for j := 0; j < linesPerRequest; j++ {
+			l.InfoContext(ctx, "line", "line", j)
+		}
+	}
+
+	// Every request is logged in full or not at all
+	counts := map[int64]int{}
+	for _, r := range tester.Records {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "request" {
+				counts[a.Value.Int64()]++
+			}
+			return true
+		})
+	}
+	for request, count := range counts {
+		if count != linesPerRequest {
+			t.Errorf("Expected request %d to be logged in full; Got %d lines", request, count)
+		}
+	}
+	if len(counts) < requests/4 || len(counts) > requests*3/4 {
+		t.Errorf("Expected about half of the requests to be kept; Got: %d", len(counts))
+	}
+
+	// Enabled agrees with the decision
+	h := yasctx.NewHandlerWithOptions(&test.Handler{}, &yasctx.HandlerOptions{SampleRate: 0.5})
+	for i := 0; i < requests; i++ {
+		ctx := yasctx.WithSampleSeed(context.Background(), fmt.Sprintf("request-%d", i))
+		_, kept := counts[int64(i)]
+		if enabled := h.Enabled(ctx, slog.LevelInfo); enabled != kept {
+			t.Errorf("Expected Enabled %v for request %d; Got: %v", kept, i, enabled)
+		}
+	}
+}
+
+func TestSampleRateDisabled(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+	for i := 0; i < 10; i++ {
+		l.InfoContext(yasctx.WithSampleSeed(context.Background(), fmt.Sprint(i)), "line")
+	}
+	if len(tester.Records) != 10 {
+		t.Errorf("Expected all lines to be kept without a sample rate; Got: %d", len(tester.Records))
+	}
+}
+
+func TestKeySampleRates(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		KeySampleRates: map[string]float64{"user_id": 0.25, "region": 1, "env": 0},
+	}))
+
+	ctx := yasctx.Add(context.Background(), "user_id", 24680, "region", "eu", "env", "prod")
+	const records = 1000
+	for i := 0; i < records; i++ {
+		l.InfoContext(ctx, "line")
+	}
+
+	counts := map[string]int{}
+	for _, r := range tester.Records {
+		r.Attrs(func(a slog.Attr) bool {
+			counts[a.Key]++
+			return true
+		})
+	}
+	if counts["region"] != records || counts["env"] != records {
+		t.Errorf("Expected unsampled keys on every record; Got: %v", counts)
+	}
+	if n := counts["user_id"]; n < records/8 || n > records*3/8 {
+		t.Errorf("Expected user_id on about a quarter of the records; Got: %d", n)
+	}
+}
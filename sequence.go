@@ -0,0 +1,44 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// SequenceScope is the scope of the sequence numbers added by HandlerOptions.Sequence.
+type SequenceScope int
+
+const (
+	// SequenceNone disables sequence numbers.
+	SequenceNone SequenceScope = iota
+
+	// SequenceHandler numbers the log lines of a handler, including those of
+	// the handlers derived from it with WithAttrs and WithGroup.
+	SequenceHandler
+
+	// SequenceContext numbers the log lines of each context initialized by
+	// InitPropagation, such as each request. Log lines using a context without
+	// propagation initialized are not numbered.
+	SequenceContext
+)
+
+// handlerSequenceExtractor returns an AttrExtractor that adds a "seq"
+// attribute, counting the records it is called for.
+func handlerSequenceExtractor() AttrExtractor {
+	var seq atomic.Uint64
+	return func(_ context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+		return []slog.Attr{slog.Uint64("seq", seq.Add(1))}
+	}
+}
+
+// extractContextSequence adds a "seq" attribute, counting the records using
+// the context's propagation collector.
+func extractContextSequence(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	m := fromCtx(ctx)
+	if m == nil {
+		return nil
+	}
+	return []slog.Attr{slog.Uint64("seq", m.seq.Add(1))}
+}
@@ -0,0 +1,63 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestSequenceHandler(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{Sequence: yasctx.SequenceHandler}))
+
+	l.Info("first")
+	l.With("with1", "arg1").InfoContext(yasctx.InitPropagation(context.Background()), "second")
+	l.WithGroup("group1").Info("third")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first seq=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=second seq=2 with1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg=third seq=3
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+
+	// Another handler has its own sequence
+	other := &test.Handler{}
+	slog.New(yasctx.NewHandlerWithOptions(other, &yasctx.HandlerOptions{Sequence: yasctx.SequenceHandler})).Info("other")
+	expected = `time=2023-09-29T13:00:59.000Z level=INFO msg=other seq=1
+`
+	if s := other.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestSequenceContext(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{Sequence: yasctx.SequenceContext}))
+
+	request1 := yasctx.InitPropagation(context.Background())
+	request2 := yasctx.InitPropagation(context.Background())
+	l.InfoContext(request1, "first")
+	l.InfoContext(yasctx.Add(request1, "child", true), "second")
+	l.InfoContext(request2, "other request")
+	l.InfoContext(request1, "third")
+	l.InfoContext(context.Background(), "no propagation")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first seq=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=second child=true seq=2
+time=2023-09-29T13:00:59.000Z level=INFO msg="other request" seq=1
+time=2023-09-29T13:00:59.000Z level=INFO msg=third seq=3
+time=2023-09-29T13:00:59.000Z level=INFO msg="no propagation"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,36 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type spansKey struct{}
+
+// RecordSpan records that the context has traversed the trace span with the
+// given id. Log lines using the returned context include a "span_count"
+// attribute with the number of distinct spans recorded, which reveals how
+// many spans a complex flow has gone through.
+// Recording the same span id again does not change the count.
+func RecordSpan(parent context.Context, spanID string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	v, _ := parent.Value(spansKey{}).([]string)
+	if slices.Contains(v, spanID) {
+		return parent
+	}
+	// Clip to ensure this is a scoped copy
+	return withFeature(context.WithValue(parent, spansKey{}, append(slices.Clip(v), spanID)), featureSpans)
+}
+
+// extractSpanCount returns the number of distinct spans recorded by RecordSpan.
+func extractSpanCount(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if v, ok := ctx.Value(spansKey{}).([]string); ok {
+		return []slog.Attr{slog.Int("span_count", len(v))}
+	}
+	return nil
+}
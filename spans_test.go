@@ -0,0 +1,39 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestRecordSpan(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := context.Background()
+	l.InfoContext(ctx, "no spans")
+
+	ctx = yasctx.RecordSpan(ctx, "00f067aa0ba902b7")
+	ctx = yasctx.RecordSpan(ctx, "53995c3f42cd8ad8")
+	ctx = yasctx.RecordSpan(ctx, "00f067aa0ba902b7") // repeat
+	l.InfoContext(ctx, "two spans")
+
+	child := yasctx.RecordSpan(ctx, "b7ad6b7169203331")
+	child = yasctx.RecordSpan(child, "53995c3f42cd8ad8") // repeat
+	l.InfoContext(child, "three spans")
+	l.InfoContext(ctx, "parent still has two")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="no spans"
+time=2023-09-29T13:00:59.000Z level=INFO msg="two spans" span_count=2
+time=2023-09-29T13:00:59.000Z level=INFO msg="three spans" span_count=3
+time=2023-09-29T13:00:59.000Z level=INFO msg="parent still has two" span_count=2
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,52 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unsafe"
+)
+
+// ContextStats describes the attributes accumulated in a context.
+type ContextStats struct {
+	// Attrs is the number of attributes, counting those nested in groups.
+	Attrs int
+
+	// Bytes is the approximate memory held by the attributes, counting the
+	// attributes themselves, their keys, and their string values.
+	// Values of KindAny are counted only by the size of the attribute.
+	Bytes int
+}
+
+// Stats reports the attributes accumulated in the context, by Add,
+// AddToGroup, AddToName, and AddWithPropagation.
+// It helps diagnose leaks, where a long-lived context accumulates an
+// unbounded number of attributes (such as adding to the same context in a loop).
+func Stats(ctx context.Context) ContextStats {
+	var stats ContextStats
+	stats.add(extractPropagatedAttrs(ctx, time.Time{}, 0, ""))
+	stats.add(extractAdded(ctx, time.Time{}, 0, ""))
+	for _, attrs := range extractAddedToGroup(ctx, time.Time{}, 0, "") {
+		stats.add(attrs)
+	}
+	if v, ok := ctx.Value(addToNameKey{}).(map[string][]slog.Attr); ok {
+		for _, attrs := range v {
+			stats.add(attrs)
+		}
+	}
+	return stats
+}
+
+// add counts the attributes, recursing into groups.
+func (s *ContextStats) add(attrs []slog.Attr) {
+	for _, a := range attrs {
+		s.Attrs++
+		s.Bytes += int(unsafe.Sizeof(a)) + len(a.Key)
+		switch a.Value.Kind() {
+		case slog.KindString:
+			s.Bytes += len(a.Value.String())
+		case slog.KindGroup:
+			s.add(a.Value.Group())
+		}
+	}
+}
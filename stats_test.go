@@ -0,0 +1,50 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	if stats := yasctx.Stats(context.Background()); stats != (yasctx.ContextStats{}) {
+		t.Errorf("Expected empty stats; Got: %+v", stats)
+	}
+
+	ctx := yasctx.InitPropagation(context.Background())
+	ctx = yasctx.AddWithPropagation(ctx, "trace_id", "abc")
+	ctx = yasctx.Add(ctx, "key", "value", slog.Group("group", "inner", 1))
+	ctx = yasctx.AddToGroup(ctx, "request", "method", "GET")
+	ctx = yasctx.AddToName(ctx, "db", "table", "users")
+
+	stats := yasctx.Stats(ctx)
+	if stats.Attrs != 6 {
+		t.Errorf("Expected 6 attrs; Got: %+v", stats)
+	}
+	if stats.Bytes <= 0 {
+		t.Errorf("Expected positive bytes; Got: %+v", stats)
+	}
+
+	// Simulate a leak, where a long-lived context accumulates attributes
+	leaky := ctx
+	for i := 0; i < 100; i++ {
+		leaky = yasctx.Add(leaky, "payload", strings.Repeat("x", 100))
+	}
+	leakyStats := yasctx.Stats(leaky)
+	if leakyStats.Attrs != stats.Attrs+100 {
+		t.Errorf("Expected %d attrs; Got: %+v", stats.Attrs+100, leakyStats)
+	}
+	if leakyStats.Bytes < stats.Bytes+100*100 {
+		t.Errorf("Expected at least %d bytes; Got: %+v", stats.Bytes+100*100, leakyStats)
+	}
+
+	// The parent context is unaffected
+	if again := yasctx.Stats(ctx); again != stats {
+		t.Errorf("Expected parent stats %+v; Got: %+v", stats, again)
+	}
+}
@@ -0,0 +1,40 @@
+package yasctx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/pazams/yasctx/internal/test"
+)
+
+type failingHandler struct{ test.Handler }
+
+func (h *failingHandler) Handle(ctx context.Context, r slog.Record) error {
+	_ = h.Handler.Handle(ctx, r)
+	return io.ErrClosedPipe
+}
+
+func TestHandlerTee(t *testing.T) {
+	t.Parallel()
+
+	tester, tee := &test.Handler{}, &failingHandler{}
+	l := slog.New(NewHandlerWithOptions(tester, &HandlerOptions{
+		Tee:       tee,
+		Appenders: []AttrExtractor{StaticExtractor("app1", "arg1")},
+	}))
+
+	ctx := Add(nil, "ctx1", "arg1")
+	ctx = AddToGroup(ctx, "group1", "ctx2", "arg1")
+	l.WithGroup("group1").With("with1", "arg1").InfoContext(ctx, "main message", "main1", "arg1")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ctx1=arg1 group1.ctx2=arg1 group1.with1=arg1 group1.main1=arg1 app1=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if s := tee.String(); s != expected {
+		t.Errorf("Expected tee:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
@@ -0,0 +1,34 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type tenantKey struct{}
+
+// WithTenant sets the tenant for all future log lines using the returned
+// context, which are then tagged with a "tenant_id" attribute.
+// If HandlerOptions.TenantHandlers has a handler for the tenant, the log lines
+// are routed to it, isolating the logs of each tenant.
+func WithTenant(parent context.Context, tenantID string) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return withFeature(context.WithValue(parent, tenantKey{}, tenantID), featureTenant)
+}
+
+// tenantFromCtx returns the tenant set by WithTenant.
+func tenantFromCtx(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok
+}
+
+// extractTenant returns the tenant set by WithTenant.
+func extractTenant(ctx context.Context, _ time.Time, _ slog.Level, _ string) []slog.Attr {
+	if tenantID, ok := tenantFromCtx(ctx); ok {
+		return []slog.Attr{slog.String("tenant_id", tenantID)}
+	}
+	return nil
+}
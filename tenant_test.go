@@ -0,0 +1,57 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestWithTenant(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	l.InfoContext(yasctx.WithTenant(context.Background(), "acme"), "main message", "main1", "arg1")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" tenant_id=acme main1=arg1
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestTenantHandlers(t *testing.T) {
+	t.Parallel()
+
+	shared, acme, globex := &test.Handler{}, &test.Handler{}, &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(shared, &yasctx.HandlerOptions{
+		TenantHandlers: map[string]slog.Handler{"acme": acme, "globex": globex},
+	})).WithGroup("group1").With("with1", "arg1")
+
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	l.InfoContext(yasctx.WithTenant(ctx, "acme"), "acme message")
+	l.InfoContext(yasctx.WithTenant(ctx, "globex"), "globex message")
+	l.InfoContext(yasctx.WithTenant(ctx, "initech"), "initech message")
+	l.InfoContext(ctx, "no tenant")
+
+	for name, tc := range map[string]struct {
+		sink     *test.Handler
+		expected string
+	}{
+		"acme": {acme, `time=2023-09-29T13:00:59.000Z level=INFO msg="acme message" request_id=abc tenant_id=acme group1.with1=arg1
+`},
+		"globex": {globex, `time=2023-09-29T13:00:59.000Z level=INFO msg="globex message" request_id=abc tenant_id=globex group1.with1=arg1
+`},
+		"shared": {shared, `time=2023-09-29T13:00:59.000Z level=INFO msg="initech message" request_id=abc tenant_id=initech group1.with1=arg1
+time=2023-09-29T13:00:59.000Z level=INFO msg="no tenant" request_id=abc group1.with1=arg1
+`},
+	} {
+		if s := tc.sink.String(); s != tc.expected {
+			t.Errorf("%s expected:\n%s\nGot:\n%s\n", name, tc.expected, s)
+		}
+	}
+}
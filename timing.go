@@ -0,0 +1,96 @@
+package yasctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/pazams/yasctx/internal/attr"
+)
+
+type startKey struct{}
+type ttlKey struct{}
+
+// ttlAttr is an attribute that expires at a point in time.
+type ttlAttr struct {
+	attr    slog.Attr
+	expires time.Time
+}
+
+// MarkStart stores the current time (of the clock set by WithClock) in the returned context, as the start of
+// the request (or any other lifecycle). If the Handler is configured with
+// HandlerOptions.RequestDuration, every log line using the context includes a
+// "request_duration" attribute with the time elapsed since the start.
+func MarkStart(parent context.Context) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, startKey{}, clockFromCtx(parent).Now())
+}
+
+// SinceLastExtractor returns an AttrExtractor that adds a "since_last"
+// attribute with the time elapsed since the previous log line using the same
+// context's propagation collector (such as the same request), to help spot
+// latency gaps. The first log line of a context has no "since_last", and log
+// lines using a context without propagation initialized by InitPropagation
+// are skipped.
+// Times are those of the records (or of HandlerOptions.Clock).
+func SinceLastExtractor() AttrExtractor {
+	return func(ctx context.Context, recordT time.Time, _ slog.Level, _ string) []slog.Attr {
+		m := fromCtx(ctx)
+		if m == nil {
+			return nil
+		}
+		last := m.last.Swap(recordT.UnixNano())
+		if last == 0 {
+			return nil
+		}
+		return []slog.Attr{slog.Duration("since_last", time.Duration(recordT.UnixNano()-last))}
+	}
+}
+
+// extractRequestDuration returns the time elapsed between MarkStart and the record
+// (or the time of HandlerOptions.Clock).
+func extractRequestDuration(ctx context.Context, recordT time.Time, _ slog.Level, _ string) []slog.Attr {
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok {
+		return []slog.Attr{slog.Duration("request_duration", recordT.Sub(start))}
+	}
+	return nil
+}
+
+// AddWithTTL adds the attribute arguments at the root level, like Add, but
+// only for log lines written within ttl of now (of the clock set by WithClock). This is useful for attributes
+// that should only tag log lines within a short window.
+func AddWithTTL(parent context.Context, ttl time.Duration, args ...any) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	expires := clockFromCtx(parent).Now().Add(ttl)
+	attrs := allowedAttrs(parent, attr.ArgsToAttrSlice(args))
+	added := make([]ttlAttr, len(attrs))
+	for i, a := range attrs {
+		added[i] = ttlAttr{attr: a, expires: expires}
+	}
+
+	v, _ := parent.Value(ttlKey{}).([]ttlAttr)
+	// Clip to ensure this is a scoped copy
+	return withFeature(context.WithValue(parent, ttlKey{}, append(slices.Clip(v), added...)), featureTTL)
+}
+
+// extractTTLAttrs returns the attributes added with AddWithTTL that have not
+// expired as of the record's time (or the time of HandlerOptions.Clock).
+func extractTTLAttrs(ctx context.Context, recordT time.Time, _ slog.Level, _ string) []slog.Attr {
+	v, ok := ctx.Value(ttlKey{}).([]ttlAttr)
+	if !ok {
+		return nil
+	}
+	var attrs []slog.Attr
+	for _, a := range v {
+		if recordT.Before(a.expires) {
+			attrs = append(attrs, a.attr)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,161 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestMarkStart(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{RequestDuration: true}))
+
+	l.InfoContext(context.Background(), "not started")
+
+	ctx := yasctx.MarkStart(context.Background())
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		l.InfoContext(ctx, "started")
+	}
+
+	// Without the option, the duration is not emitted
+	slog.New(yasctx.NewHandler(tester)).InfoContext(ctx, "no option")
+
+	if n := tester.Records[0].NumAttrs(); n != 0 {
+		t.Errorf("Expected no attributes without MarkStart; Got %d", n)
+	}
+	if n := tester.Records[4].NumAttrs(); n != 0 {
+		t.Errorf("Expected no attributes without the option; Got %d", n)
+	}
+
+	var previous time.Duration
+	for _, r := range tester.Records[1:4] {
+		var d time.Duration
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "request_duration" {
+				d = a.Value.Duration()
+			}
+			return true
+		})
+		if d <= previous {
+			t.Errorf("Expected increasing request_duration; Got %s after %s", d, previous)
+		}
+		previous = d
+	}
+}
+
+func TestAddWithTTL(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	h := yasctx.NewHandler(tester)
+
+	start := time.Now()
+	ctx := yasctx.Add(context.Background(), "request_id", "abc")
+	ctx = yasctx.AddWithTTL(ctx, time.Minute, "phase", "warmup")
+	ctx = yasctx.AddWithTTL(ctx, time.Hour, "deploy", "canary")
+
+	for _, offset := range []time.Duration{0, 30 * time.Second, 2 * time.Minute, 2 * time.Hour} {
+		r := slog.NewRecord(start.Add(offset), slog.LevelInfo, "after "+offset.String(), 0)
+		if err := h.Handle(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="after 0s" request_id=abc phase=warmup deploy=canary
+time=2023-09-29T13:00:59.000Z level=INFO msg="after 30s" request_id=abc phase=warmup deploy=canary
+time=2023-09-29T13:00:59.000Z level=INFO msg="after 2m0s" request_id=abc deploy=canary
+time=2023-09-29T13:00:59.000Z level=INFO msg="after 2h0m0s" request_id=abc
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+// fakeClock is a yasctx.Clock that only moves when advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: test.DefaultTime}
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		RequestDuration: true,
+		Clock:           clock,
+	}))
+
+	ctx := yasctx.MarkStart(yasctx.WithClock(context.Background(), clock))
+	ctx = yasctx.AddWithTTL(ctx, 5*time.Second, "retrying", true)
+
+	l.InfoContext(ctx, "first")
+	clock.Advance(3 * time.Second)
+	l.InfoContext(ctx, "second")
+	clock.Advance(3 * time.Second)
+	l.InfoContext(ctx, "third")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first retrying=true request_duration=0s
+time=2023-09-29T13:00:59.000Z level=INFO msg=second retrying=true request_duration=3s
+time=2023-09-29T13:00:59.000Z level=INFO msg=third request_duration=6s
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestSinceLastExtractor(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: test.DefaultTime}
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		Appenders: []yasctx.AttrExtractor{yasctx.SinceLastExtractor()},
+		Clock:     clock,
+	}))
+
+	request1 := yasctx.InitPropagation(context.Background())
+	request2 := yasctx.InitPropagation(context.Background())
+
+	l.InfoContext(request1, "first")
+	clock.Advance(time.Second)
+	l.InfoContext(yasctx.Add(request1, "child", true), "second")
+	clock.Advance(3 * time.Second)
+	l.InfoContext(request2, "other request")
+	l.InfoContext(request1, "third")
+	clock.Advance(10 * time.Second)
+	l.InfoContext(request1, "fourth")
+	l.InfoContext(context.Background(), "no propagation")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=first
+time=2023-09-29T13:00:59.000Z level=INFO msg=second child=true since_last=1s
+time=2023-09-29T13:00:59.000Z level=INFO msg="other request"
+time=2023-09-29T13:00:59.000Z level=INFO msg=third since_last=3s
+time=2023-09-29T13:00:59.000Z level=INFO msg=fourth since_last=10s
+time=2023-09-29T13:00:59.000Z level=INFO msg="no propagation"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
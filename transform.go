@@ -0,0 +1,178 @@
+package yasctx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type transformsKey struct{}
+
+// WithTransform registers fn to transform the context attributes of the log
+// lines using the returned context (such as to reshape them for a specific
+// request), after any transformers registered earlier in the context.
+// The prepended and appended context attributes are each passed to the
+// transformers in turn, after they are extracted and before they are merged
+// with the record's attributes. The transformers may modify the slice they are
+// passed, which is a copy.
+func WithTransform(parent context.Context, fn func([]slog.Attr) []slog.Attr) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	v, _ := parent.Value(transformsKey{}).([]func([]slog.Attr) []slog.Attr)
+	// Clip to ensure this is a scoped copy
+	return context.WithValue(parent, transformsKey{}, append(slices.Clip(v), fn))
+}
+
+// transformCtxAttrs returns the attributes passed through the transformers
+// registered with WithTransform, in order.
+func transformCtxAttrs(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	transforms, _ := ctx.Value(transformsKey{}).([]func([]slog.Attr) []slog.Attr)
+	if len(transforms) == 0 || len(attrs) == 0 {
+		return attrs
+	}
+	// The transformers may modify the slice they are given in place
+	attrs = slices.Clone(attrs)
+	for _, transform := range transforms {
+		attrs = transform(attrs)
+	}
+	return slices.Clip(attrs)
+}
+
+// Case is a letter case that string values can be normalized to.
+type Case int
+
+const (
+	// CaseLower normalizes string values to lower case.
+	CaseLower Case = iota
+
+	// CaseUpper normalizes string values to upper case.
+	CaseUpper
+)
+
+// BytesEncoding is how []byte values are encoded as strings.
+type BytesEncoding int
+
+const (
+	// BytesUnchanged leaves []byte values for the next handler to encode.
+	BytesUnchanged BytesEncoding = iota
+
+	// BytesBase64 encodes []byte values as standard base64.
+	BytesBase64
+
+	// BytesHex encodes []byte values as lowercase hexadecimal.
+	BytesHex
+
+	// BytesPreview encodes []byte values as a Go quoted string of their first
+	// 16 bytes, followed by their total length if they are longer.
+	BytesPreview
+)
+
+// bytesPreviewLen is the number of bytes shown by BytesPreview.
+const bytesPreviewLen = 16
+
+// encodeBytes returns the value with []byte values encoded as strings,
+// recursing into groups. Values of other kinds are returned unchanged.
+func encodeBytes(v slog.Value, enc BytesEncoding) slog.Value {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, a := range group {
+			attrs[i] = slog.Attr{Key: a.Key, Value: encodeBytes(a.Value, enc)}
+		}
+		return slog.GroupValue(attrs...)
+	case slog.KindAny:
+		b, ok := v.Any().([]byte)
+		if !ok {
+			return v
+		}
+		switch enc {
+		case BytesBase64:
+			return slog.StringValue(base64.StdEncoding.EncodeToString(b))
+		case BytesHex:
+			return slog.StringValue(hex.EncodeToString(b))
+		case BytesPreview:
+			if len(b) <= bytesPreviewLen {
+				return slog.StringValue(strconv.Quote(string(b)))
+			}
+			return slog.StringValue(strconv.Quote(string(b[:bytesPreviewLen])) + "...(" + strconv.Itoa(len(b)) + " bytes)")
+		}
+	}
+	return v
+}
+
+// caseTransformer returns a value transformer that normalizes string values to the case.
+// Values of other kinds are returned unchanged.
+func caseTransformer(c Case) func(slog.Value) slog.Value {
+	return func(v slog.Value) slog.Value {
+		v = v.Resolve()
+		if v.Kind() != slog.KindString {
+			return v
+		}
+		if c == CaseUpper {
+			return slog.StringValue(strings.ToUpper(v.String()))
+		}
+		return slog.StringValue(strings.ToLower(v.String()))
+	}
+}
+
+// buildValueTransformers combines the ValueTransformers and NormalizeCase
+// options into a single transformer per key. For keys found in both, the
+// ValueTransformer is applied first.
+func buildValueTransformers(opts *HandlerOptions) map[string]func(slog.Value) slog.Value {
+	if len(opts.ValueTransformers) == 0 && len(opts.NormalizeCase) == 0 {
+		return nil
+	}
+
+	transformers := make(map[string]func(slog.Value) slog.Value, len(opts.ValueTransformers)+len(opts.NormalizeCase))
+	for key, fn := range opts.ValueTransformers {
+		transformers[key] = fn
+	}
+	for key, c := range opts.NormalizeCase {
+		normalize := caseTransformer(c)
+		if fn, ok := transformers[key]; ok {
+			transformers[key] = func(v slog.Value) slog.Value { return normalize(fn(v)) }
+		} else {
+			transformers[key] = normalize
+		}
+	}
+	return transformers
+}
+
+// capSlices returns a new slice of the attributes, with slice values longer
+// than their maximum length (from perKey, or max) capped to their last
+// elements, each followed by a "_<key>_more" attribute with the number of
+// elements dropped.
+func capSlices(attrs []slog.Attr, max int, perKey map[string]int) []slog.Attr {
+	capped := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		n := max
+		if keyMax, ok := perKey[a.Key]; ok {
+			n = keyMax
+		}
+		a.Value = a.Value.Resolve()
+		if n <= 0 || a.Value.Kind() != slog.KindAny {
+			capped = append(capped, a)
+			continue
+		}
+		v := reflect.ValueOf(a.Value.Any())
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 || v.Len() <= n {
+			capped = append(capped, a)
+			continue
+		}
+		more := v.Len() - n
+		capped = append(capped,
+			slog.Any(a.Key, v.Slice(more, v.Len()).Interface()),
+			slog.Int("_"+a.Key+"_more", more),
+		)
+	}
+	return capped
+}
@@ -0,0 +1,207 @@
+package yasctx_test
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	yasctx "github.com/pazams/yasctx"
+	"github.com/pazams/yasctx/internal/test"
+)
+
+func TestNormalizeCase(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		NormalizeCase: map[string]yasctx.Case{
+			"env":    yasctx.CaseLower,
+			"region": yasctx.CaseUpper,
+			"count":  yasctx.CaseUpper,
+			"code":   yasctx.CaseLower,
+		},
+		ValueTransformers: map[string]func(slog.Value) slog.Value{
+			"code": func(v slog.Value) slog.Value { return slog.StringValue(strings.TrimSpace(v.String())) },
+		},
+	}))
+
+	ctx := yasctx.Add(context.Background(), "env", "PROD", "region", "eu-west", "count", 3, "name", "MixedCase")
+	ctx = yasctx.AddToGroup(ctx, "group1", "env", "Staging")
+	ctx = yasctx.AddWithPropagation(ctx, "code", " ABC ")
+
+	l.InfoContext(ctx, "main message", "env", "NotFromCtx")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" env=prod region=EU-WEST count=3 name=MixedCase code=abc env=staging env=NotFromCtx
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestKeyRemap(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		dedup    bool
+		expected string
+	}{
+		{
+			dedup: false,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user_id=24680 user_id=13579 env=PROD group1.trace_id=abc group1.main1=arg1
+`,
+		},
+		{
+			dedup: true,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user_id=13579 env=PROD group1.trace_id=abc group1.main1=arg1
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+			KeyRemap: map[string]string{
+				"uid":         "user_id",
+				"environment": "env",
+				"tid":         "trace_id",
+			},
+			NormalizeCase: map[string]yasctx.Case{"env": yasctx.CaseUpper},
+			Dedup:         tc.dedup,
+		}))
+
+		// "uid" collides with "user_id" once remapped
+		ctx := yasctx.Add(context.Background(), "uid", 24680, "user_id", 13579, "environment", "prod")
+		ctx = yasctx.AddToGroup(ctx, "group1", "tid", "abc")
+
+		l.WithGroup("group1").InfoContext(ctx, "main message", "main1", "arg1")
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("Dedup %v expected:\n%s\nGot:\n%s\n", tc.dedup, tc.expected, s)
+		}
+	}
+}
+
+func TestBytesEncoding(t *testing.T) {
+	t.Parallel()
+
+	ctx := yasctx.Add(context.Background(),
+		"short", []byte("hi\n"),
+		"long", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n"),
+		slog.Group("group", "payload", []byte{0xff, 0x00}),
+		"text", "not bytes",
+	)
+
+	for _, tc := range []struct {
+		encoding yasctx.BytesEncoding
+		expected string
+	}{
+		{
+			encoding: yasctx.BytesBase64,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" short=aGkK long="R0VUIC8gSFRUUC8xLjENCkhvc3Q6IGV4YW1wbGUuY29tDQo=" group.payload="/wA=" text="not bytes"
+`,
+		},
+		{
+			encoding: yasctx.BytesHex,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" short=68690a long=474554202f20485454502f312e310d0a486f73743a206578616d706c652e636f6d0d0a group.payload=ff00 text="not bytes"
+`,
+		},
+		{
+			encoding: yasctx.BytesPreview,
+			expected: `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" short="\"hi\\n\"" long="\"GET / HTTP/1.1\\r\\n\"...(35 bytes)" group.payload="\"\\xff\\x00\"" text="not bytes"
+`,
+		},
+	} {
+		tester := &test.Handler{}
+		l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{BytesEncoding: tc.encoding}))
+		l.InfoContext(ctx, "main message")
+
+		if s := tester.String(); s != tc.expected {
+			t.Errorf("Encoding %d expected:\n%s\nGot:\n%s\n", tc.encoding, tc.expected, s)
+		}
+	}
+}
+
+func TestTypedAttrs(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{TypedAttrs: true}))
+
+	ctx := yasctx.Add(context.Background(),
+		"user_id", 42,
+		"ratio", 0.5,
+		"admin", true,
+		"name", "alice",
+		"timeout", 3*time.Second,
+		slog.Group("req", "size", uint64(7)),
+	)
+	l.InfoContext(ctx, "main message", "record", "untyped")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" user_id=42 user_id_type=Int64 ratio=0.5 ratio_type=Float64 admin=true admin_type=Bool name=alice name_type=String timeout=3s timeout_type=Duration req.size=7 req.size_type=Uint64 req_type=Group record=untyped
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestWithTransform(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandler(tester))
+
+	ctx := yasctx.Add(context.Background(), "user_id", 42, "email", "alice@example.com")
+	redacted := yasctx.WithTransform(ctx, func(attrs []slog.Attr) []slog.Attr {
+		for i := range attrs {
+			if attrs[i].Key == "email" {
+				attrs[i].Value = slog.StringValue("REDACTED")
+			}
+		}
+		return attrs
+	})
+	// Runs after the first transformer, so it sees the redacted value
+	grouped := yasctx.WithTransform(redacted, func(attrs []slog.Attr) []slog.Attr {
+		return []slog.Attr{{Key: "user", Value: slog.GroupValue(attrs...)}}
+	})
+
+	l.InfoContext(grouped, "transformed", "record", 1)
+	l.InfoContext(redacted, "redacted only")
+	l.InfoContext(ctx, "untouched")
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg=transformed user.user_id=42 user.email=REDACTED record=1
+time=2023-09-29T13:00:59.000Z level=INFO msg="redacted only" user_id=42 email=REDACTED
+time=2023-09-29T13:00:59.000Z level=INFO msg=untouched user_id=42 email=alice@example.com
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+}
+
+func TestMaxSliceLen(t *testing.T) {
+	t.Parallel()
+
+	tester := &test.Handler{}
+	l := slog.New(yasctx.NewHandlerWithOptions(tester, &yasctx.HandlerOptions{
+		MaxSliceLen:  3,
+		MaxSliceLens: map[string]int{"retries": 1, "tags": 0},
+	}))
+
+	ids := []int{1, 2, 3, 4, 5}
+	ctx := yasctx.Add(context.Background(),
+		"ids", ids,
+		"retries", []string{"first", "second"},
+		"tags", []string{"a", "b", "c", "d"},
+		"short", []string{"x"},
+		"raw", []byte("abcdef"),
+	)
+	l.InfoContext(ctx, "main message", "record", []int{1, 2, 3, 4})
+
+	expected := `time=2023-09-29T13:00:59.000Z level=INFO msg="main message" ids="[3 4 5]" _ids_more=2 retries=[second] _retries_more=1 tags="[a b c d]" short=[x] raw="abcdef" record="[1 2 3 4]"
+`
+	if s := tester.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s\n", expected, s)
+	}
+	if len(ids) != 5 || ids[0] != 1 {
+		t.Errorf("Expected the added slice to be untouched; Got: %v", ids)
+	}
+}
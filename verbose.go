@@ -0,0 +1,22 @@
+package yasctx
+
+import "context"
+
+type verboseKey struct{}
+
+// WithVerbose sets whether log lines using the returned context include the
+// attributes of HandlerOptions.VerboseExtractors. This lets operators turn
+// on detailed enrichment for a single request (such as with the X-Debug
+// header, through VerboseMiddleware), without changing the global configuration.
+func WithVerbose(parent context.Context, verbose bool) context.Context {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithValue(parent, verboseKey{}, verbose)
+}
+
+// isVerbose reports whether verbose was set by WithVerbose.
+func isVerbose(ctx context.Context) bool {
+	verbose, _ := ctx.Value(verboseKey{}).(bool)
+	return verbose
+}
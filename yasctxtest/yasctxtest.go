@@ -0,0 +1,53 @@
+// Package yasctxtest provides helpers for testing code that logs with yasctx.
+package yasctxtest
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// ContainsAttr reports whether any of the records has an attribute with the
+// dotted key (such as "group1.key1") and value.
+// The key is matched regardless of whether its parts are nested groups or are
+// part of a flattened key, so that tests are robust to changes in grouping:
+// "group1.key1" matches a group "group1" holding "key1", as well as a root
+// level "group1.key1" attribute.
+// Values are compared as slog.Values, after resolving any LogValuers.
+func ContainsAttr(records []slog.Record, dottedKey string, value any) bool {
+	want := slog.AnyValue(value).Resolve()
+	for _, r := range records {
+		found := false
+		r.Attrs(func(a slog.Attr) bool {
+			found = containsAttr(a, dottedKey, want)
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAttr reports whether the attribute, or any attribute in it if it is
+// a group, has the dotted key and value.
+func containsAttr(a slog.Attr, dottedKey string, want slog.Value) bool {
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return a.Key == dottedKey && v.Equal(want)
+	}
+
+	// Groups with an empty key are inlined
+	rest := dottedKey
+	if a.Key != "" {
+		var ok bool
+		if rest, ok = strings.CutPrefix(dottedKey, a.Key+"."); !ok {
+			return false
+		}
+	}
+	for _, inner := range v.Group() {
+		if containsAttr(inner, rest, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package yasctxtest
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestContainsAttr(t *testing.T) {
+	t.Parallel()
+
+	flat := slog.NewRecord(time.Time{}, slog.LevelInfo, "flat", 0)
+	flat.AddAttrs(slog.String("request.id", "abc"), slog.Int("count", 3))
+
+	grouped := slog.NewRecord(time.Time{}, slog.LevelInfo, "grouped", 0)
+	grouped.AddAttrs(
+		slog.Group("request", slog.String("id", "def"), slog.Group("user", slog.Int("id", 24680))),
+		slog.Group("", slog.Bool("inlined", true)),
+		slog.Group("mixed", slog.String("sub.key", "value")),
+	)
+
+	for _, tc := range []struct {
+		records  []slog.Record
+		key      string
+		value    any
+		expected bool
+	}{
+		{[]slog.Record{flat}, "request.id", "abc", true},
+		{[]slog.Record{flat}, "count", 3, true},
+		{[]slog.Record{flat}, "count", "3", false},
+		{[]slog.Record{grouped}, "request.id", "def", true},
+		{[]slog.Record{grouped}, "request.user.id", 24680, true},
+		{[]slog.Record{grouped}, "user.id", 24680, false},
+		{[]slog.Record{grouped}, "inlined", true, true},
+		{[]slog.Record{grouped}, "mixed.sub.key", "value", true},
+		{[]slog.Record{grouped}, "request", "def", false},
+		{[]slog.Record{flat, grouped}, "request.id", "def", true},
+		{[]slog.Record{flat, grouped}, "request.id", "xyz", false},
+		{nil, "request.id", "abc", false},
+	} {
+		if got := ContainsAttr(tc.records, tc.key, tc.value); got != tc.expected {
+			t.Errorf("ContainsAttr(%q, %v): expected %t; Got: %t", tc.key, tc.value, tc.expected, got)
+		}
+	}
+}